@@ -4,7 +4,10 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"forum/backend/middleware"
 	"forum/backend/models"
+	"forum/backend/websocket"
 	"log"
 	"net/http"
 
@@ -12,7 +15,8 @@ import (
 )
 
 type AuthController struct {
-	DB *sql.DB
+	DB  *sql.DB
+	Hub *websocket.Hub
 }
 
 type RegisterRequest struct {
@@ -89,13 +93,13 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	log.Printf("User registered successfully with ID: %d", userID)
 
 	// Create session
-	sessionID, err := models.CreateSession(c.DB, int(userID))
+	session, err := models.CreateSession(c.DB, int(userID), r.UserAgent(), clientIP(r))
 	if err != nil {
 		log.Printf("Session creation error: %v", err)
 		http.Error(w, "Error creating session: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Session created with ID: %s", sessionID)
+	log.Printf("Session created with ID: %s", session.ID)
 
 	// Get complete user data
 	user, err = models.GetUserByID(c.DB, int(userID))
@@ -105,19 +109,12 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   86400 * 7, // 7 days
-		SameSite: http.SameSiteLaxMode,
-	})
+	middleware.SetSessionCookie(w, session)
 
 	// Return user and session data
 	response := AuthResponse{
 		User:      user,
-		SessionID: sessionID,
+		SessionID: session.ID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -169,23 +166,16 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Password verified successfully for user %d", user.ID)
 
 	// Create session
-	sessionID, err := models.CreateSession(c.DB, user.ID)
+	session, err := models.CreateSession(c.DB, user.ID, r.UserAgent(), clientIP(r))
 	if err != nil {
 		log.Printf("Session creation error for user %d: %v", user.ID, err)
 		http.Error(w, "Error creating session: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Session created with ID: %s for user %d", sessionID, user.ID)
+	log.Printf("Session created with ID: %s for user %d", session.ID, user.ID)
 
 	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   86400 * 7, // 7 days
-		SameSite: http.SameSiteLaxMode,
-	})
+	middleware.SetSessionCookie(w, session)
 
 	// Clear password before sending to client
 	user.Password = ""
@@ -193,7 +183,7 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	// Return user and session data
 	response := AuthResponse{
 		User:      user,
-		SessionID: sessionID,
+		SessionID: session.ID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -222,14 +212,11 @@ func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Close any WebSocket connections opened with this session
+	c.Hub.DisconnectSession(cookie.Value)
+
 	// Clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1,
-	})
+	middleware.ClearSessionCookie(w)
 
 	// Return success
 	w.WriteHeader(http.StatusOK)
@@ -258,8 +245,16 @@ func (c *AuthController) GetCurrentUser(w http.ResponseWriter, r *http.Request)
 
 	// Get session
 	session, err := models.GetSessionByID(c.DB, cookie.Value)
+	if errors.Is(err, models.ErrSessionExpired) {
+		log.Printf("Expired session ID %s", cookie.Value)
+		middleware.ClearSessionCookie(w)
+		w.Header().Set(middleware.SessionStatusHeader, "expired")
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		log.Printf("Invalid session ID %s: %v", cookie.Value, err)
+		w.Header().Set(middleware.SessionStatusHeader, "invalid")
 		http.Error(w, "Invalid session", http.StatusUnauthorized)
 		return
 	}
@@ -0,0 +1,170 @@
+// backend/controllers/bookmark.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"forum/backend/models"
+)
+
+// BookmarkRequest files a bookmark in a folder; without one, or with an
+// empty folder, the bookmark is unfiled
+type BookmarkRequest struct {
+	Folder string `json:"folder"`
+}
+
+// BookmarkResponse tells whether a post or comment is now bookmarked and in
+// which folder
+type BookmarkResponse struct {
+	Bookmarked bool   `json:"bookmarked"`
+	Folder     string `json:"folder"`
+}
+
+// BookmarkPost saves a post to the user's bookmarks, or moves it to another
+// folder
+func (c *PostController) BookmarkPost(w http.ResponseWriter, r *http.Request, userID int) {
+	c.bookmark(w, r, userID, models.TargetPost)
+}
+
+// BookmarkComment saves a comment to the user's bookmarks, or moves it to
+// another folder
+func (c *PostController) BookmarkComment(w http.ResponseWriter, r *http.Request, userID int) {
+	c.bookmark(w, r, userID, models.TargetComment)
+}
+
+// UnbookmarkPost removes a post from the user's bookmarks
+func (c *PostController) UnbookmarkPost(w http.ResponseWriter, r *http.Request, userID int) {
+	c.unbookmark(w, r, userID, models.TargetPost)
+}
+
+// UnbookmarkComment removes a comment from the user's bookmarks
+func (c *PostController) UnbookmarkComment(w http.ResponseWriter, r *http.Request, userID int) {
+	c.unbookmark(w, r, userID, models.TargetComment)
+}
+
+func (c *PostController) bookmark(w http.ResponseWriter, r *http.Request, userID int, targetType string) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid "+targetType+" ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional
+	var req BookmarkRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	folder, err := models.NormalizeBookmarkFolder(req.Folder)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Folder names can be at most %d characters", models.MaxBookmarkFolderLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.AddBookmark(c.DB, userID, targetType, targetID, folder); err != nil {
+		if errors.Is(err, models.ErrBookmarkTargetNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error saving bookmark", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BookmarkResponse{Bookmarked: true, Folder: folder})
+}
+
+func (c *PostController) unbookmark(w http.ResponseWriter, r *http.Request, userID int, targetType string) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid "+targetType+" ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemoveBookmark(c.DB, userID, targetType, targetID); err != nil {
+		http.Error(w, "Error removing bookmark", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BookmarkResponse{Bookmarked: false})
+}
+
+// GetBookmarks retrieves a page of the user's bookmarks, newest first,
+// optionally only those in one folder. cursor is the nextCursor of the
+// previous page.
+func (c *PostController) GetBookmarks(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultPostPageSize
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxPostPageSize {
+		limit = maxPostPageSize
+	}
+
+	before := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		before, err = strconv.Atoi(cursor)
+		if err != nil || before <= 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	folder, err := models.NormalizeBookmarkFolder(query.Get("folder"))
+	if err != nil {
+		http.Error(w, "Invalid folder", http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.GetBookmarks(c.DB, userID, folder, before, limit)
+	if err != nil {
+		http.Error(w, "Error retrieving bookmarks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetBookmarkFolders lists the user's bookmark folders with how many
+// bookmarks each holds
+func (c *PostController) GetBookmarkFolders(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	folders, err := models.GetBookmarkFolders(c.DB, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving bookmark folders", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folders)
+}
@@ -0,0 +1,181 @@
+// backend/controllers/category.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/backend/models"
+)
+
+type CategoryController struct {
+	DB *sql.DB
+}
+
+type CategoryRequest struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sortOrder"`
+}
+
+// GetCategories lists every category in display order
+func (c *CategoryController) GetCategories(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	categories, err := models.GetAllCategories(c.DB)
+	if err != nil {
+		http.Error(w, "Error retrieving categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// CreateCategory adds a category; admins only
+func (c *CategoryController) CreateCategory(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdmin(w, c.DB, userID, "Only admins can manage categories") {
+		return
+	}
+
+	category, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := models.CreateCategory(c.DB, category)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	log.Printf("User %d created category %d", userID, id)
+
+	category, err = models.GetCategoryByID(c.DB, int(id))
+	if err != nil {
+		http.Error(w, "Error retrieving category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory changes a category; admins only
+func (c *CategoryController) UpdateCategory(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdmin(w, c.DB, userID, "Only admins can manage categories") {
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	category, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+	category.ID = id
+
+	if err := models.UpdateCategory(c.DB, category); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	log.Printf("User %d updated category %d", userID, id)
+
+	category, err = models.GetCategoryByID(c.DB, id)
+	if err != nil {
+		http.Error(w, "Error retrieving category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// DeleteCategory removes a category from the forum and from every post; admins only
+func (c *CategoryController) DeleteCategory(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdmin(w, c.DB, userID, "Only admins can manage categories") {
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteCategory(c.DB, id); err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+	log.Printf("User %d deleted category %d", userID, id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted"})
+}
+
+// decodeCategory reads and validates a category from the request body
+func decodeCategory(w http.ResponseWriter, r *http.Request) (models.Category, bool) {
+	var req CategoryRequest
+	if !decodeBody(w, r, &req) {
+		return models.Category{}, false
+	}
+
+	category := models.Category{
+		Slug:        models.Slugify(req.Slug),
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		SortOrder:   req.SortOrder,
+	}
+	if category.Name == "" {
+		http.Error(w, "Category name is required", http.StatusBadRequest)
+		return category, false
+	}
+	if category.Slug == "" && models.Slugify(category.Name) == "" {
+		http.Error(w, "Category needs a slug made of letters or digits", http.StatusBadRequest)
+		return category, false
+	}
+	return category, true
+}
+
+// writeCategoryError maps a category model error to a response
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrCategoryNotFound):
+		http.Error(w, "Category not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrCategoryExists):
+		http.Error(w, "A category with this slug already exists", http.StatusConflict)
+	default:
+		http.Error(w, "Error saving category", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,185 @@
+// backend/controllers/draft.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/backend/models"
+)
+
+// SaveDraftRequest is an autosave of a draft. ID is 0 for a new draft. A
+// draft with PublishAt set is published by the scheduler at that time;
+// sending null unschedules it, and leaving PublishAt out keeps its schedule.
+type SaveDraftRequest struct {
+	ID          int          `json:"id"`
+	Title       string       `json:"title"`
+	Content     string       `json:"content"`
+	CategoryIDs []int        `json:"categoryIds"`
+	Tags        []string     `json:"tags"`
+	PublishAt   optionalTime `json:"publishAt"`
+}
+
+// optionalTime is a time in a request body that can be set, sent as null or
+// left out; Sent tells the last apart from the other two
+type optionalTime struct {
+	Sent bool
+	Time *time.Time
+}
+
+func (t *optionalTime) UnmarshalJSON(data []byte) error {
+	t.Sent = true
+	if string(data) == "null" {
+		t.Time = nil
+		return nil
+	}
+	return json.Unmarshal(data, &t.Time)
+}
+
+// SaveDraft creates or updates one of the user's drafts. Drafts may be
+// incomplete, unless they are scheduled.
+func (c *PostController) SaveDraft(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SaveDraftRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if !checkLength(w, req.Title, req.Content) {
+		return
+	}
+
+	// A draft that will stay scheduled must be complete enough to publish
+	scheduled := req.PublishAt.Time != nil
+	if req.PublishAt.Time != nil && !req.PublishAt.Time.After(time.Now()) {
+		http.Error(w, "Publish time must be in the future", http.StatusBadRequest)
+		return
+	}
+	if !req.PublishAt.Sent && req.ID != 0 {
+		existing, err := models.GetPostByID(c.DB, req.ID, userID)
+		if err != nil && !errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Error retrieving draft", http.StatusInternalServerError)
+			return
+		}
+		scheduled = err == nil && existing.Status == models.PostDraft && existing.PublishAt != nil
+	}
+	if scheduled && (req.Title == "" || req.Content == "" || len(req.CategoryIDs) == 0) {
+		http.Error(w, "Title, content, and at least one category are required to schedule a post", http.StatusBadRequest)
+		return
+	}
+
+	categories, ok := c.lookupCategories(w, req.CategoryIDs)
+	if !ok {
+		return
+	}
+	tags, ok := normalizeTags(w, req.Tags)
+	if !ok {
+		return
+	}
+
+	draft := models.Post{
+		ID:         req.ID,
+		UserID:     userID,
+		Title:      req.Title,
+		Content:    req.Content,
+		Categories: categories,
+		Tags:       tags,
+		PublishAt:  req.PublishAt.Time,
+	}
+	draftID, err := models.SaveDraft(c.DB, draft, req.PublishAt.Sent)
+	if err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error saving draft", http.StatusInternalServerError)
+		return
+	}
+
+	draft, err = models.GetPostByID(c.DB, draftID, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving draft", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// GetDrafts lists the user's drafts, most recently saved first
+func (c *PostController) GetDrafts(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	drafts, err := models.GetDrafts(c.DB, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving drafts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drafts)
+}
+
+// PublishDraft publishes one of the user's drafts right away
+func (c *PostController) PublishDraft(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	draft, err := models.GetPostByID(c.DB, postID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error retrieving draft", http.StatusInternalServerError)
+		return
+	}
+	if draft.UserID != userID || draft.Status != models.PostDraft {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return
+	}
+
+	// Published posts need everything a new post does
+	if draft.Title == "" || draft.Content == "" || len(draft.Categories) == 0 {
+		http.Error(w, "Title, content, and at least one category are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.PublishDraft(c.DB, postID, userID); err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error publishing draft", http.StatusInternalServerError)
+		return
+	}
+
+	post, err := models.GetPostByID(c.DB, postID, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
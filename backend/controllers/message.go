@@ -4,22 +4,28 @@ package controllers
 import (
     "database/sql"
     "encoding/json"
+    "errors"
     "net/http"
     "strconv"
-    "time"
     "forum/backend/models"
+    "forum/backend/websocket"
 )
 
+// maxMessagePageSize caps the number of messages returned per page
+const maxMessagePageSize = 50
+
 type MessageController struct {
-    DB *sql.DB
+    DB  *sql.DB
+    Hub *websocket.Hub
 }
 
 type SendMessageRequest struct {
     ReceiverID int    `json:"receiverId"`
     Content    string `json:"content"`
+    ImageURL   string `json:"imageUrl"`
 }
 
-// SendMessage handles sending a new message
+// SendMessage handles sending a new message through the hub's send pipeline
 func (c *MessageController) SendMessage(w http.ResponseWriter, r *http.Request, senderID int) {
     // Only allow POST method
     if r.Method != http.MethodPost {
@@ -27,55 +33,33 @@ func (c *MessageController) SendMessage(w http.ResponseWriter, r *http.Request,
         return
     }
     
-    var req struct {
-        ReceiverID int    `json:"receiverId"`
-        Content    string `json:"content"`
-        ImageURL   string `json:"imageUrl"`
-    }
-    
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
-        return
-    }
-    
-    // Validate request
-    if req.ReceiverID <= 0 || (req.Content == "" && req.ImageURL == "") {
-        http.Error(w, "Receiver ID and either content or image are required", http.StatusBadRequest)
+    var req SendMessageRequest
+    if !decodeBody(w, r, &req) {
         return
     }
     
-    // Create message
-    message := models.Message{
-        SenderID:   senderID,
+    // Validate, store and deliver the message
+    message, err := c.Hub.SendChatMessage(senderID, websocket.ChatMessage{
         ReceiverID: req.ReceiverID,
         Content:    req.Content,
         ImageURL:   req.ImageURL,
-        CreatedAt:  time.Now(),
-    }
-    
-    // Save to database
-    messageID, err := models.CreateMessage(c.DB, message)
-    if err != nil {
-        http.Error(w, "Error sending message", http.StatusInternalServerError)
+    })
+    var ferr *websocket.FrameError
+    if errors.As(err, &ferr) && ferr.Code == websocket.ErrCodeInvalid {
+        http.Error(w, ferr.Message, http.StatusBadRequest)
         return
     }
-    
-    // Get sender info for response
-    sender, err := models.GetUserByID(c.DB, senderID)
     if err != nil {
-        http.Error(w, "Error retrieving sender info", http.StatusInternalServerError)
+        http.Error(w, "Error sending message", http.StatusInternalServerError)
         return
     }
     
-    message.ID = int(messageID)
-    message.Sender = sender
-    
     // Return message data
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(message)
 }
 
-// GetMessages retrieves messages between two users with pagination
+// GetMessages retrieves a page of messages between two users
 func (c *MessageController) GetMessages(w http.ResponseWriter, r *http.Request, userID int) {
     // Only allow GET method
     if r.Method != http.MethodGet {
@@ -97,25 +81,38 @@ func (c *MessageController) GetMessages(w http.ResponseWriter, r *http.Request,
     }
     
     // Get pagination parameters
-    limitStr := r.URL.Query().Get("limit")
-    offsetStr := r.URL.Query().Get("offset")
+    query := r.URL.Query()
     
     limit := 10 // Default limit
-    if limitStr != "" {
-        if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-            limit = l
-        }
+    if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+        limit = l
+    }
+    if limit > maxMessagePageSize {
+        limit = maxMessagePageSize
     }
     
-    offset := 0 // Default offset
-    if offsetStr != "" {
-        if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-            offset = o
+    // Cursors are message IDs: "before" pages back through history,
+    // "after" fetches messages newer than the one the client has
+    beforeID, afterID := 0, 0
+    if before := query.Get("before"); before != "" {
+        if beforeID, err = strconv.Atoi(before); err != nil || beforeID <= 0 {
+            http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+            return
         }
     }
+    if after := query.Get("after"); after != "" {
+        if afterID, err = strconv.Atoi(after); err != nil || afterID <= 0 {
+            http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+            return
+        }
+    }
+    if beforeID > 0 && afterID > 0 {
+        http.Error(w, "Use either before or after, not both", http.StatusBadRequest)
+        return
+    }
     
     // Get messages
-    messages, err := models.GetMessagesBetweenUsers(c.DB, userID, otherID, limit, offset)
+    page, err := models.GetMessagesBetweenUsers(c.DB, userID, otherID, limit, beforeID, afterID)
     if err != nil {
         http.Error(w, "Error retrieving messages", http.StatusInternalServerError)
         return
@@ -123,7 +120,7 @@ func (c *MessageController) GetMessages(w http.ResponseWriter, r *http.Request,
     
     // Return messages
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(messages)
+    json.NewEncoder(w).Encode(page)
 }
 
 // GetChats retrieves recent chats for the current user
@@ -149,9 +146,41 @@ func (c *MessageController) GetChats(w http.ResponseWriter, r *http.Request, use
     }
     
     // Combine both lists
-    allUsers := append(recentUsers, otherUsers...)
+    allUsers := recentUsers
+    for _, user := range otherUsers {
+        allUsers = append(allUsers, models.ChatSummary{User: user})
+    }
     
     // Return chat list
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(allUsers)
+}
+
+// MarkRead marks the conversation with another user as read up to a message
+func (c *MessageController) MarkRead(w http.ResponseWriter, r *http.Request, userID int) {
+    // Only allow POST method
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    var req websocket.MarkReadMessage
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    
+    receipt, err := c.Hub.MarkConversationRead(userID, req)
+    var ferr *websocket.FrameError
+    if errors.As(err, &ferr) && ferr.Code == websocket.ErrCodeInvalid {
+        http.Error(w, ferr.Message, http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Error marking messages read", http.StatusInternalServerError)
+        return
+    }
+    
+    // Return the receipt
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(receipt)
 }
\ No newline at end of file
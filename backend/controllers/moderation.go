@@ -0,0 +1,178 @@
+// backend/controllers/moderation.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/backend/models"
+)
+
+// PinPostRequest pins or unpins a post on the front page when CategoryID is
+// 0, or in one of the post's categories. Pinned posts are listed by ascending
+// Position.
+type PinPostRequest struct {
+	Pinned     bool `json:"pinned"`
+	CategoryID int  `json:"categoryId"`
+	Position   int  `json:"position"`
+}
+
+// LockPostRequest locks or unlocks a post's comments
+type LockPostRequest struct {
+	Locked bool `json:"locked"`
+}
+
+// ArchivePostRequest archives or restores a post
+type ArchivePostRequest struct {
+	Archived bool `json:"archived"`
+}
+
+// PinPost pins a post to the top of a feed, or unpins it
+func (c *PostController) PinPost(w http.ResponseWriter, r *http.Request, userID int) {
+	postID, ok := c.authorizeModeration(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req PinPostRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var err error
+	if req.Pinned {
+		err = models.PinPost(c.DB, postID, req.CategoryID, req.Position, userID)
+	} else {
+		err = models.UnpinPost(c.DB, postID, req.CategoryID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrPostNotFound):
+			http.Error(w, "Post not found", http.StatusNotFound)
+		case errors.Is(err, models.ErrPostArchived):
+			http.Error(w, "Archived posts can't be pinned", http.StatusBadRequest)
+		case errors.Is(err, models.ErrPostNotInCategory):
+			http.Error(w, "Post is not in that category", http.StatusBadRequest)
+		default:
+			http.Error(w, "Error pinning post", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Post %d pinned=%t in category %d by user %d", postID, req.Pinned, req.CategoryID, userID)
+	c.writePost(w, postID, userID)
+}
+
+// LockPost closes a post to new comments, or reopens it
+func (c *PostController) LockPost(w http.ResponseWriter, r *http.Request, userID int) {
+	postID, ok := c.authorizeModeration(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req LockPostRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := models.SetPostLocked(c.DB, postID, req.Locked); err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error locking post", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Post %d locked=%t by user %d", postID, req.Locked, userID)
+	c.writePost(w, postID, userID)
+}
+
+// ArchivePost makes a post read-only and hides it from the default feed, or
+// restores it
+func (c *PostController) ArchivePost(w http.ResponseWriter, r *http.Request, userID int) {
+	postID, ok := c.authorizeModeration(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req ArchivePostRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := models.SetPostArchived(c.DB, postID, req.Archived); err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error archiving post", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Post %d archived=%t by user %d", postID, req.Archived, userID)
+	c.writePost(w, postID, userID)
+}
+
+// authorizeModeration checks the request is a POST by a moderator and reads
+// the post ID from the URL. It writes the error response itself and reports
+// false when the request is not allowed.
+func (c *PostController) authorizeModeration(w http.ResponseWriter, r *http.Request, userID int) (int, bool) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return 0, false
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	user, err := models.GetUserByID(c.DB, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+		return 0, false
+	}
+	if !user.IsModerator() {
+		http.Error(w, "Only moderators can pin, lock or archive posts", http.StatusForbidden)
+		return 0, false
+	}
+	return postID, true
+}
+
+// requireAdmin writes a 403 response with the forbidden message and reports
+// false unless the user is an admin
+func requireAdmin(w http.ResponseWriter, db *sql.DB, userID int, forbidden string) bool {
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+		return false
+	}
+	if !user.IsAdmin() {
+		http.Error(w, forbidden, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writePost responds with a post as the user sees it
+func (c *PostController) writePost(w http.ResponseWriter, postID, userID int) {
+	post, err := models.GetPostByID(c.DB, postID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
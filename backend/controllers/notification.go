@@ -0,0 +1,158 @@
+// backend/controllers/notification.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/backend/models"
+	"forum/backend/websocket"
+)
+
+// FollowResponse tells whether the user now follows a post
+type FollowResponse struct {
+	Following bool `json:"following"`
+}
+
+// ReadNotificationsRequest marks notifications up to UpToID as read, or all
+// of them without it
+type ReadNotificationsRequest struct {
+	UpToID int `json:"upToId"`
+}
+
+// ReadNotificationsResponse tells how many notifications are still unread
+type ReadNotificationsResponse struct {
+	UnreadCount int `json:"unreadCount"`
+}
+
+// FollowPost makes the user follow a post, to be notified of new comments on
+// it
+func (c *PostController) FollowPost(w http.ResponseWriter, r *http.Request, userID int) {
+	c.setFollowing(w, r, userID, true)
+}
+
+// UnfollowPost stops the user's notifications for a post, including one they
+// wrote or commented on
+func (c *PostController) UnfollowPost(w http.ResponseWriter, r *http.Request, userID int) {
+	c.setFollowing(w, r, userID, false)
+}
+
+func (c *PostController) setFollowing(w http.ResponseWriter, r *http.Request, userID int, following bool) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	if following {
+		err = models.FollowPost(c.DB, postID, userID)
+	} else {
+		err = models.UnfollowPost(c.DB, postID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating follow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FollowResponse{Following: following})
+}
+
+// GetNotifications retrieves a page of the user's notifications, newest
+// first, with how many are unread. cursor is the nextCursor of the previous
+// page.
+func (c *PostController) GetNotifications(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultPostPageSize
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxPostPageSize {
+		limit = maxPostPageSize
+	}
+
+	before := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		before, err = strconv.Atoi(cursor)
+		if err != nil || before <= 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := models.GetNotifications(c.DB, userID, before, limit)
+	if err != nil {
+		http.Error(w, "Error retrieving notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ReadNotifications marks the user's notifications as read, up to upToId
+// when it is given
+func (c *PostController) ReadNotifications(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The body is optional
+	var req ReadNotificationsRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if req.UpToID < 0 {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	unread, err := models.MarkNotificationsRead(c.DB, userID, req.UpToID)
+	if err != nil {
+		http.Error(w, "Error updating notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReadNotificationsResponse{UnreadCount: unread})
+}
+
+// notifyFollowers stores a notification of a new comment for each follower
+// of its post and pushes it to those who are online. The comment is already
+// saved, so failures are only logged.
+func (c *PostController) notifyFollowers(commentID int) {
+	notifications, unread, err := models.NotifyNewComment(c.DB, commentID)
+	if err != nil {
+		log.Printf("Error notifying followers of comment %d: %v", commentID, err)
+		return
+	}
+
+	for _, n := range notifications {
+		c.Hub.SendToUser("notification", websocket.NotificationMessage{
+			Notification: n,
+			UnreadCount:  unread[n.UserID],
+		}, n.UserID)
+	}
+}
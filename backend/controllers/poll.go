@@ -0,0 +1,157 @@
+// backend/controllers/poll.go
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/websocket"
+)
+
+// PollRequest is a poll to attach to a new post. ClosesAt is optional; a
+// poll without it stays open.
+type PollRequest struct {
+	Question  string     `json:"question"`
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple"`
+	Anonymous bool       `json:"anonymous"`
+	ClosesAt  *time.Time `json:"closesAt"`
+}
+
+// VoteRequest is a user's ballot: one option, or several in a multiple
+// choice poll
+type VoteRequest struct {
+	OptionIDs []int `json:"optionIds"`
+}
+
+// Vote casts the user's ballot in a poll, replacing any earlier one
+func (c *PostController) Vote(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pollID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid poll ID", http.StatusBadRequest)
+		return
+	}
+
+	var req VoteRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	postID, err := models.Vote(c.DB, pollID, userID, req.OptionIDs)
+	if err != nil {
+		writePollError(w, err)
+		return
+	}
+
+	c.writePollUpdate(w, pollID, postID, userID)
+}
+
+// Unvote takes back the user's ballot in a poll
+func (c *PostController) Unvote(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pollID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid poll ID", http.StatusBadRequest)
+		return
+	}
+
+	postID, err := models.Unvote(c.DB, pollID, userID)
+	if err != nil {
+		writePollError(w, err)
+		return
+	}
+
+	c.writePollUpdate(w, pollID, postID, userID)
+}
+
+// writePollUpdate responds with a poll as the user sees it and pushes its
+// new tallies to every connected client
+func (c *PostController) writePollUpdate(w http.ResponseWriter, pollID, postID, userID int) {
+	poll, err := models.GetPoll(c.DB, pollID, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving poll", http.StatusInternalServerError)
+		return
+	}
+
+	update := websocket.PollUpdateMessage{
+		PollID:      poll.ID,
+		PostID:      postID,
+		TotalVoters: poll.TotalVoters,
+		Options:     make([]websocket.PollTally, len(poll.Options)),
+	}
+	for i, option := range poll.Options {
+		update.Options[i] = websocket.PollTally{OptionID: option.ID, Votes: option.Votes, Voters: option.Voters}
+	}
+	c.Hub.BroadcastState("poll_update", strconv.Itoa(pollID), update)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// writePollError maps a poll model error to a response
+func writePollError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrPollNotFound):
+		http.Error(w, "Poll not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrPollClosed):
+		http.Error(w, "This poll is closed", http.StatusForbidden)
+	case errors.Is(err, models.ErrPostArchived):
+		http.Error(w, "This post is archived and read-only", http.StatusForbidden)
+	case errors.Is(err, models.ErrInvalidVote):
+		http.Error(w, "Pick one option, or several in a multiple choice poll", http.StatusBadRequest)
+	default:
+		http.Error(w, "Error saving vote", http.StatusInternalServerError)
+	}
+}
+
+// readPoll validates the poll of a new post. It writes the error response
+// itself and reports false when the poll can't be used.
+func readPoll(w http.ResponseWriter, req *PollRequest) (*models.Poll, bool) {
+	if req == nil {
+		return nil, true
+	}
+
+	poll := &models.Poll{
+		Question:  strings.TrimSpace(req.Question),
+		Multiple:  req.Multiple,
+		Anonymous: req.Anonymous,
+		ClosesAt:  req.ClosesAt,
+	}
+	if poll.Question == "" {
+		http.Error(w, "Poll question is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	for _, text := range req.Options {
+		if text = strings.TrimSpace(text); text != "" {
+			poll.Options = append(poll.Options, models.PollOption{Text: text})
+		}
+	}
+	if len(poll.Options) < models.MinPollOptions || len(poll.Options) > models.MaxPollOptions {
+		http.Error(w, fmt.Sprintf("A poll needs %d to %d options", models.MinPollOptions, models.MaxPollOptions), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if poll.ClosesAt != nil && !poll.ClosesAt.After(time.Now()) {
+		http.Error(w, "Poll close time must be in the future", http.StatusBadRequest)
+		return nil, false
+	}
+	return poll, true
+}
@@ -4,23 +4,65 @@ package controllers
 import (
     "database/sql"
     "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
     "net/http"
     "strconv"
+    "strings"
+    "time"
     "forum/backend/models"
+    "forum/backend/websocket"
+    
+    "github.com/gofrs/uuid"
 )
 
 type PostController struct {
-    DB *sql.DB
+    DB  *sql.DB
+    Hub *websocket.Hub
 }
 
+const (
+    // Posts per feed page unless the client asks for fewer
+    defaultPostPageSize = 20
+    
+    // Largest feed page a client may request
+    maxPostPageSize = 50
+    
+    // Deepest comment tree and most replies per comment a client may request
+    maxCommentDepth    = 10
+    maxCommentPageSize = 50
+    
+    // Largest JSON body accepted for a post, comment or draft; room for the
+    // longest content even with every character escaped
+    maxBodySize = 1 << 20
+)
+
 type CreatePostRequest struct {
-    Title    string `json:"title"`
-    Content  string `json:"content"`
-    Category string `json:"category"`
+    Title         string       `json:"title"`
+    Content       string       `json:"content"`
+    CategoryIDs   []int        `json:"categoryIds"`
+    Tags          []string     `json:"tags"`
+    Poll          *PollRequest `json:"poll"`
+    AttachmentIDs []int        `json:"attachmentIds"`
+}
+
+type EditPostRequest struct {
+    Title       string   `json:"title"`
+    Content     string   `json:"content"`
+    CategoryIDs []int    `json:"categoryIds"`
+    Tags        []string `json:"tags"`
+}
+
+type ReactionRequest struct {
+    Reaction string `json:"reaction"`
 }
 
 type CreateCommentRequest struct {
-    Content string `json:"content"`
+    Content       string `json:"content"`
+    ParentID      int    `json:"parentId"`
+    AttachmentIDs []int  `json:"attachmentIds"`
 }
 
 // CreatePost handles new post creation
@@ -32,34 +74,59 @@ func (c *PostController) CreatePost(w http.ResponseWriter, r *http.Request, user
     }
     
     var req CreatePostRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
+    if !decodeBody(w, r, &req) {
         return
     }
     
     // Validate request
-    if req.Title == "" || req.Content == "" || req.Category == "" {
-        http.Error(w, "Title, content, and category are required", http.StatusBadRequest)
+    if req.Title == "" || req.Content == "" || len(req.CategoryIDs) == 0 {
+        http.Error(w, "Title, content, and at least one category are required", http.StatusBadRequest)
+        return
+    }
+    if !checkLength(w, req.Title, req.Content) {
+        return
+    }
+    
+    categories, ok := c.lookupCategories(w, req.CategoryIDs)
+    if !ok {
+        return
+    }
+    tags, ok := normalizeTags(w, req.Tags)
+    if !ok {
+        return
+    }
+    poll, ok := readPoll(w, req.Poll)
+    if !ok {
+        return
+    }
+    attachments, ok := readAttachments(w, req.AttachmentIDs)
+    if !ok {
         return
     }
     
     // Create post
     post := models.Post{
-        UserID:   userID,
-        Title:    req.Title,
-        Content:  req.Content,
-        Category: req.Category,
+        UserID:      userID,
+        Title:       req.Title,
+        Content:     req.Content,
+        Categories:  categories,
+        Tags:        tags,
+        Poll:        poll,
+        Attachments: attachments,
     }
     
     // Save to database
     postID, err := models.CreatePost(c.DB, post)
     if err != nil {
+        if writeAttachmentError(w, err) {
+            return
+        }
         http.Error(w, "Error creating post", http.StatusInternalServerError)
         return
     }
     
     // Get complete post with user data
-    post, err = models.GetPostByID(c.DB, int(postID))
+    post, err = models.GetPostByID(c.DB, int(postID), userID)
     if err != nil {
         http.Error(w, "Error retrieving post", http.StatusInternalServerError)
         return
@@ -70,28 +137,107 @@ func (c *PostController) CreatePost(w http.ResponseWriter, r *http.Request, user
     json.NewEncoder(w).Encode(post)
 }
 
-// GetAllPosts retrieves all posts
-func (c *PostController) GetAllPosts(w http.ResponseWriter, r *http.Request) {
+// GetAllPosts retrieves a page of the post feed. viewerID is 0 for anonymous
+// visitors.
+func (c *PostController) GetAllPosts(w http.ResponseWriter, r *http.Request, viewerID int) {
     // Only allow GET method
     if r.Method != http.MethodGet {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
     
+    query := r.URL.Query()
+    filter := models.PostFilter{
+        CategorySlug: query.Get("category"),
+        Sort:         query.Get("sort"),
+        Cursor:       query.Get("cursor"),
+        ViewerID:     viewerID,
+        Limit:        defaultPostPageSize,
+    }
+    
+    if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+        filter.Limit = l
+    }
+    if filter.Limit > maxPostPageSize {
+        filter.Limit = maxPostPageSize
+    }
+    
+    if filter.Sort == "" {
+        filter.Sort = models.SortNewest
+    }
+    if !models.ValidSort(filter.Sort) {
+        http.Error(w, "Sort must be newest, comments, activity, hot or top", http.StatusBadRequest)
+        return
+    }
+    
+    if window := query.Get("window"); window != "" {
+        if !models.ValidWindow(window) {
+            http.Error(w, "Window must be day, week or month", http.StatusBadRequest)
+            return
+        }
+        filter.Window = window
+    }
+    
+    if author := query.Get("author"); author != "" {
+        authorID, err := strconv.Atoi(author)
+        if err != nil || authorID <= 0 {
+            http.Error(w, "Invalid author ID", http.StatusBadRequest)
+            return
+        }
+        filter.AuthorID = authorID
+    }
+    
+    // Dates may be a whole day, which "to" includes, or an exact RFC 3339 time
+    var ok bool
+    if filter.From, ok = parseFeedDate(query.Get("from"), false); !ok {
+        http.Error(w, "Invalid from date", http.StatusBadRequest)
+        return
+    }
+    if filter.To, ok = parseFeedDate(query.Get("to"), true); !ok {
+        http.Error(w, "Invalid to date", http.StatusBadRequest)
+        return
+    }
+    
+    // Posts must have every tag given, as a comma-separated list
+    if tags := query.Get("tags"); tags != "" {
+        for _, tag := range strings.Split(tags, ",") {
+            if tag = models.NormalizeTag(tag); tag != "" {
+                filter.Tags = append(filter.Tags, tag)
+            }
+        }
+    }
+    
+    if query.Get("archived") == "true" {
+        filter.IncludeArchived = true
+    }
+    
+    if query.Get("commented") == "true" {
+        if viewerID == 0 {
+            http.Error(w, "Log in to see posts you commented on", http.StatusUnauthorized)
+            return
+        }
+        filter.CommentedBy = viewerID
+    }
+    
     // Get posts from database
-    posts, err := models.GetAllPosts(c.DB)
+    page, err := models.GetPosts(c.DB, filter)
     if err != nil {
+        if errors.Is(err, models.ErrInvalidCursor) {
+            http.Error(w, "Invalid cursor", http.StatusBadRequest)
+            return
+        }
         http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
         return
     }
     
     // Return posts
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(posts)
+    json.NewEncoder(w).Encode(page)
 }
 
-// GetPost retrieves a specific post with its comments
-func (c *PostController) GetPost(w http.ResponseWriter, r *http.Request) {
+// GetPost retrieves a specific post with its comments. viewerID is 0 for
+// anonymous visitors.
+func (c *PostController) GetPost(w http.ResponseWriter, r *http.Request, viewerID int) {
     // Only allow GET method
     if r.Method != http.MethodGet {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -111,13 +257,31 @@ func (c *PostController) GetPost(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    opts, ok := commentTreeOptions(w, r)
+    if !ok {
+        return
+    }
+    
+    // Count the view, once per user or anonymous visitor
+    if err := models.RecordView(c.DB, postID, viewerKey(w, r, viewerID)); err != nil {
+        log.Printf("Error recording view of post %d: %v", postID, err)
+    }
+    
     // Get post with comments
-    post, err := models.GetPostByID(c.DB, postID)
+    post, err := models.GetPostByID(c.DB, postID, viewerID)
     if err != nil {
         http.Error(w, "Post not found", http.StatusNotFound)
         return
     }
     
+    comments, err := models.GetCommentTree(c.DB, postID, 0, 0, viewerID, opts)
+    if err != nil {
+        http.Error(w, "Error retrieving comments", http.StatusInternalServerError)
+        return
+    }
+    post.Comments = comments.Items
+    post.CommentsCursor = comments.NextCursor
+    
     // Return post with comments
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(post)
@@ -144,9 +308,23 @@ func (c *PostController) CreateComment(w http.ResponseWriter, r *http.Request, u
         return
     }
     
+    // Comments can only be added to published posts that still exist
+    post, err := models.GetPostByID(c.DB, postID, userID)
+    if err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
+        }
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return
+    }
+    if post.Status != models.PostPublished {
+        http.Error(w, "Drafts can't be commented on", http.StatusBadRequest)
+        return
+    }
+    
     var req CreateCommentRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        http.Error(w, "Invalid request body", http.StatusBadRequest)
+    if !decodeBody(w, r, &req) {
         return
     }
     
@@ -155,38 +333,584 @@ func (c *PostController) CreateComment(w http.ResponseWriter, r *http.Request, u
         http.Error(w, "Comment content is required", http.StatusBadRequest)
         return
     }
+    if !checkLength(w, "", req.Content) {
+        return
+    }
+    attachments, ok := readAttachments(w, req.AttachmentIDs)
+    if !ok {
+        return
+    }
     
     // Create comment
     comment := models.Comment{
-        PostID:  postID,
-        UserID:  userID,
-        Content: req.Content,
+        PostID:      postID,
+        UserID:      userID,
+        Content:     req.Content,
+        Attachments: attachments,
+    }
+    
+    // A reply must be to a comment on the same post
+    if req.ParentID != 0 {
+        parent, err := models.GetCommentByID(c.DB, req.ParentID, userID)
+        if err != nil {
+            if errors.Is(err, models.ErrCommentNotFound) {
+                http.Error(w, "Parent comment not found", http.StatusBadRequest)
+                return
+            }
+            http.Error(w, "Error retrieving comment", http.StatusInternalServerError)
+            return
+        }
+        if parent.PostID != postID {
+            http.Error(w, "Parent comment is not on this post", http.StatusBadRequest)
+            return
+        }
+        comment.ParentID = &parent.ID
     }
     
     // Save to database
     commentID, err := models.CreateComment(c.DB, comment)
     if err != nil {
+        if errors.Is(err, models.ErrPostLocked) {
+            http.Error(w, "This post is locked and no longer accepts comments", http.StatusForbidden)
+            return
+        }
+        if errors.Is(err, models.ErrPostArchived) {
+            http.Error(w, "This post is archived and read-only", http.StatusForbidden)
+            return
+        }
+        if writeAttachmentError(w, err) {
+            return
+        }
         http.Error(w, "Error creating comment", http.StatusInternalServerError)
         return
     }
     
-    // Get comments for post
-    comments, err := models.GetCommentsByPostID(c.DB, postID)
+    // Get the new comment with user data
+    newComment, err := models.GetCommentByID(c.DB, int(commentID), userID)
+    if err != nil {
+        http.Error(w, "Error retrieving comment", http.StatusInternalServerError)
+        return
+    }
+    
+    // Let the post's followers know
+    c.notifyFollowers(newComment.ID)
+    
+    // Return comment data
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(newComment)
+}
+
+// GetCommentReplies loads more of a comment thread: the replies to parentId,
+// or the post's top-level comments without it, after the cursor comment.
+// viewerID is 0 for anonymous visitors.
+func (c *PostController) GetCommentReplies(w http.ResponseWriter, r *http.Request, viewerID int) {
+    // Only allow GET method
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    query := r.URL.Query()
+    postID, err := strconv.Atoi(query.Get("postId"))
+    if err != nil {
+        http.Error(w, "Invalid post ID", http.StatusBadRequest)
+        return
+    }
+    
+    parentID, afterID := 0, 0
+    if parent := query.Get("parentId"); parent != "" {
+        if parentID, err = strconv.Atoi(parent); err != nil || parentID <= 0 {
+            http.Error(w, "Invalid parent comment ID", http.StatusBadRequest)
+            return
+        }
+    }
+    if after := query.Get("after"); after != "" {
+        if afterID, err = strconv.Atoi(after); err != nil || afterID < 0 {
+            http.Error(w, "Invalid cursor", http.StatusBadRequest)
+            return
+        }
+    }
+    
+    opts, ok := commentTreeOptions(w, r)
+    if !ok {
+        return
+    }
+    
+    // Comments of deleted posts are gone with them
+    if _, err := models.GetPostByID(c.DB, postID, viewerID); err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
+        }
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return
+    }
+    
+    page, err := models.GetCommentTree(c.DB, postID, parentID, afterID, viewerID, opts)
     if err != nil {
+        if errors.Is(err, models.ErrCommentNotFound) {
+            http.Error(w, "Comment not found", http.StatusNotFound)
+            return
+        }
         http.Error(w, "Error retrieving comments", http.StatusInternalServerError)
         return
     }
     
-    // Find the new comment
-    var newComment models.Comment
-    for _, c := range comments {
-        if c.ID == int(commentID) {
-            newComment = c
-            break
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
+
+// EditPost changes a post's title, content and categories, keeping the old version as a revision
+func (c *PostController) EditPost(w http.ResponseWriter, r *http.Request, userID int) {
+    // Only allow POST method
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    postID, ok := c.authorizePostChange(w, r, userID)
+    if !ok {
+        return
+    }
+    
+    var req EditPostRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    
+    // Validate request
+    if req.Title == "" || req.Content == "" || len(req.CategoryIDs) == 0 {
+        http.Error(w, "Title, content, and at least one category are required", http.StatusBadRequest)
+        return
+    }
+    if !checkLength(w, req.Title, req.Content) {
+        return
+    }
+    
+    categories, ok := c.lookupCategories(w, req.CategoryIDs)
+    if !ok {
+        return
+    }
+    tags, ok := normalizeTags(w, req.Tags)
+    if !ok {
+        return
+    }
+    
+    post := models.Post{
+        ID:         postID,
+        Title:      req.Title,
+        Content:    req.Content,
+        Categories: categories,
+        Tags:       tags,
+    }
+    if err := models.UpdatePost(c.DB, post, userID); err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
         }
+        if errors.Is(err, models.ErrPostArchived) {
+            http.Error(w, "This post is archived and read-only", http.StatusForbidden)
+            return
+        }
+        http.Error(w, "Error updating post", http.StatusInternalServerError)
+        return
     }
     
-    // Return comment data
+    // Get updated post with user data
+    post, err := models.GetPostByID(c.DB, postID, userID)
+    if err != nil {
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return
+    }
+    
+    // Return post data
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(newComment)
-}
\ No newline at end of file
+    json.NewEncoder(w).Encode(post)
+}
+
+// DeletePost withdraws a post
+func (c *PostController) DeletePost(w http.ResponseWriter, r *http.Request, userID int) {
+    // Only allow POST method
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    postID, ok := c.authorizePostChange(w, r, userID)
+    if !ok {
+        return
+    }
+    
+    if err := models.DeletePost(c.DB, postID); err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
+        }
+        http.Error(w, "Error deleting post", http.StatusInternalServerError)
+        return
+    }
+    log.Printf("User %d deleted post %d", userID, postID)
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"message": "Post deleted"})
+}
+
+// GetPostRevisions lists every version of a post, oldest first
+func (c *PostController) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+    // Only allow GET method
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+    if err != nil {
+        http.Error(w, "Invalid post ID", http.StatusBadRequest)
+        return
+    }
+    
+    revisions, err := models.GetPostRevisions(c.DB, postID)
+    if err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
+        }
+        http.Error(w, "Error retrieving revisions", http.StatusInternalServerError)
+        return
+    }
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(revisions)
+}
+
+// GetPostDiff compares two revisions of a post. Without from and to it
+// compares the previous revision with the current one.
+func (c *PostController) GetPostDiff(w http.ResponseWriter, r *http.Request) {
+    // Only allow GET method
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    query := r.URL.Query()
+    postID, err := strconv.Atoi(query.Get("id"))
+    if err != nil {
+        http.Error(w, "Invalid post ID", http.StatusBadRequest)
+        return
+    }
+    
+    revisions, err := models.GetPostRevisions(c.DB, postID)
+    if err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return
+        }
+        http.Error(w, "Error retrieving revisions", http.StatusInternalServerError)
+        return
+    }
+    
+    to := len(revisions)
+    from := to - 1
+    if from < 1 {
+        from = 1
+    }
+    if value := query.Get("from"); value != "" {
+        if from, err = strconv.Atoi(value); err != nil {
+            http.Error(w, "Invalid from revision", http.StatusBadRequest)
+            return
+        }
+    }
+    if value := query.Get("to"); value != "" {
+        if to, err = strconv.Atoi(value); err != nil {
+            http.Error(w, "Invalid to revision", http.StatusBadRequest)
+            return
+        }
+    }
+    if from < 1 || from > len(revisions) || to < 1 || to > len(revisions) {
+        http.Error(w, "Revision not found", http.StatusNotFound)
+        return
+    }
+    
+    diff := models.DiffRevisions(revisions[from-1], revisions[to-1])
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(diff)
+}
+
+// authorizePostChange reads the post ID from the URL and checks the user may
+// change that post: only its author or a moderator can. It writes the error
+// response itself and reports false when the change is not allowed.
+func (c *PostController) authorizePostChange(w http.ResponseWriter, r *http.Request, userID int) (int, bool) {
+    postID, err := strconv.Atoi(r.URL.Query().Get("id"))
+    if err != nil {
+        http.Error(w, "Invalid post ID", http.StatusBadRequest)
+        return 0, false
+    }
+    
+    post, err := models.GetPostByID(c.DB, postID, userID)
+    if err != nil {
+        if errors.Is(err, models.ErrPostNotFound) {
+            http.Error(w, "Post not found", http.StatusNotFound)
+            return 0, false
+        }
+        http.Error(w, "Error retrieving post", http.StatusInternalServerError)
+        return 0, false
+    }
+    if post.UserID == userID {
+        return postID, true
+    }
+    
+    user, err := models.GetUserByID(c.DB, userID)
+    if err != nil {
+        http.Error(w, "Error retrieving user", http.StatusInternalServerError)
+        return 0, false
+    }
+    if !user.IsModerator() {
+        http.Error(w, "Only the author or a moderator can change this post", http.StatusForbidden)
+        return 0, false
+    }
+    return postID, true
+}
+
+// lookupCategories loads the categories chosen for a post. It writes the error
+// response itself and reports false when any of them doesn't exist.
+func (c *PostController) lookupCategories(w http.ResponseWriter, ids []int) ([]models.Category, bool) {
+    categories, err := models.GetCategoriesByIDs(c.DB, ids)
+    if err != nil {
+        if errors.Is(err, models.ErrCategoryNotFound) {
+            http.Error(w, "Unknown category", http.StatusBadRequest)
+            return nil, false
+        }
+        http.Error(w, "Error retrieving categories", http.StatusInternalServerError)
+        return nil, false
+    }
+    return categories, true
+}
+
+// normalizeTags normalizes the tags chosen for a post. It writes the error
+// response itself and reports false when they can't be used.
+func normalizeTags(w http.ResponseWriter, tags []string) ([]string, bool) {
+    normalized, err := models.NormalizeTags(tags)
+    if err != nil {
+        if errors.Is(err, models.ErrTooManyTags) {
+            http.Error(w, fmt.Sprintf("A post can have at most %d tags", models.MaxPostTags), http.StatusBadRequest)
+            return nil, false
+        }
+        http.Error(w, fmt.Sprintf("Tags must be 1 to %d letters, digits, dashes or dots", models.MaxTagLength), http.StatusBadRequest)
+        return nil, false
+    }
+    return normalized, true
+}
+
+// viewerKey identifies who is viewing a post so each viewer is counted once:
+// the user when logged in, otherwise the visitor cookie, which is set on the
+// first visit
+func viewerKey(w http.ResponseWriter, r *http.Request, viewerID int) string {
+    if viewerID != 0 {
+        return "user:" + strconv.Itoa(viewerID)
+    }
+    
+    if cookie, err := r.Cookie("visitor_id"); err == nil && cookie.Value != "" {
+        return "visitor:" + cookie.Value
+    }
+    
+    id, err := uuid.NewV4()
+    if err != nil {
+        return "visitor:" + r.RemoteAddr
+    }
+    http.SetCookie(w, &http.Cookie{
+        Name:     "visitor_id",
+        Value:    id.String(),
+        Path:     "/",
+        HttpOnly: true,
+        MaxAge:   365 * 24 * 60 * 60,
+        SameSite: http.SameSiteLaxMode,
+    })
+    return "visitor:" + id.String()
+}
+
+// decodeBody decodes a JSON request body of at most maxBodySize bytes into
+// v. It writes the error response itself and reports false when it can't.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+    return decodeJSON(w, r, v, false)
+}
+
+// decodeOptionalBody is decodeBody for requests whose body may be left out,
+// in which case v is left as it is
+func decodeOptionalBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+    return decodeJSON(w, r, v, true)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, optional bool) bool {
+    r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+    if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+        if optional && err == io.EOF {
+            return true
+        }
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+            return false
+        }
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return false
+    }
+    return true
+}
+
+// checkLength checks the title and content of a post, comment or draft
+// against their limits. It writes the error response itself and reports
+// false when either is too long.
+func checkLength(w http.ResponseWriter, title, content string) bool {
+    if len([]rune(title)) > models.MaxTitleLength {
+        http.Error(w, fmt.Sprintf("Titles can be at most %d characters", models.MaxTitleLength), http.StatusBadRequest)
+        return false
+    }
+    if len([]rune(content)) > models.MaxContentLength {
+        http.Error(w, fmt.Sprintf("Content can be at most %d characters", models.MaxContentLength), http.StatusBadRequest)
+        return false
+    }
+    return true
+}
+
+// readAttachments turns the upload IDs chosen for a post or comment into
+// attachments. It writes the error response itself and reports false when
+// there are too many.
+func readAttachments(w http.ResponseWriter, ids []int) ([]models.Attachment, bool) {
+    if len(ids) > models.MaxAttachments {
+        http.Error(w, fmt.Sprintf("At most %d files can be attached", models.MaxAttachments), http.StatusBadRequest)
+        return nil, false
+    }
+    
+    attachments := make([]models.Attachment, len(ids))
+    for i, id := range ids {
+        attachments[i].ID = id
+    }
+    return attachments, true
+}
+
+// writeAttachmentError responds to an upload that couldn't be attached and
+// reports whether err was one
+func writeAttachmentError(w http.ResponseWriter, err error) bool {
+    switch {
+    case errors.Is(err, models.ErrAttachmentNotFound):
+        http.Error(w, "Unknown attachment", http.StatusBadRequest)
+    case errors.Is(err, models.ErrAttachmentNotOwned):
+        http.Error(w, "You can only attach your own uploads", http.StatusForbidden)
+    case errors.Is(err, models.ErrAttachmentInUse):
+        http.Error(w, "That file is already attached elsewhere", http.StatusBadRequest)
+    default:
+        return false
+    }
+    return true
+}
+
+// parseFeedDate reads a feed date filter. A date without a time means the
+// start of that day, or for an end date the start of the next day. An empty
+// value means no limit.
+func parseFeedDate(value string, end bool) (time.Time, bool) {
+    if value == "" {
+        return time.Time{}, true
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, true
+    }
+    t, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        return time.Time{}, false
+    }
+    if end {
+        t = t.AddDate(0, 0, 1)
+    }
+    return t, true
+}
+
+// ReactToPost likes or dislikes a post, or takes the reaction back
+func (c *PostController) ReactToPost(w http.ResponseWriter, r *http.Request, userID int) {
+    c.react(w, r, userID, models.TargetPost)
+}
+
+// ReactToComment likes or dislikes a comment, or takes the reaction back
+func (c *PostController) ReactToComment(w http.ResponseWriter, r *http.Request, userID int) {
+    c.react(w, r, userID, models.TargetComment)
+}
+
+// react toggles the user's reaction to a post or comment, returns the new
+// counts and pushes them to every connected client
+func (c *PostController) react(w http.ResponseWriter, r *http.Request, userID int, targetType string) {
+    // Only allow POST method
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    targetID, err := strconv.Atoi(r.URL.Query().Get("id"))
+    if err != nil {
+        http.Error(w, "Invalid "+targetType+" ID", http.StatusBadRequest)
+        return
+    }
+    
+    var req ReactionRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    if req.Reaction != models.ReactionLike && req.Reaction != models.ReactionDislike {
+        http.Error(w, "Reaction must be like or dislike", http.StatusBadRequest)
+        return
+    }
+    
+    postID, reactions, err := models.ToggleReaction(c.DB, userID, targetType, targetID, req.Reaction)
+    if err != nil {
+        if errors.Is(err, models.ErrReactionTargetNotFound) {
+            http.Error(w, "Not found", http.StatusNotFound)
+            return
+        }
+        if errors.Is(err, models.ErrPostArchived) {
+            http.Error(w, "This post is archived and read-only", http.StatusForbidden)
+            return
+        }
+        http.Error(w, "Error saving reaction", http.StatusInternalServerError)
+        return
+    }
+    
+    // Everyone sees the new counts; only this user's own reaction is personal
+    c.Hub.BroadcastState("reaction_update", targetType+":"+strconv.Itoa(targetID), websocket.ReactionUpdateMessage{
+        TargetType: targetType,
+        TargetID:   targetID,
+        PostID:     postID,
+        Likes:      reactions.Likes,
+        Dislikes:   reactions.Dislikes,
+    })
+    
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(reactions)
+}
+
+// commentTreeOptions reads how much of a comment thread to return from the
+// depth and replies query parameters. It writes the error response itself
+// and reports false when they are invalid.
+func commentTreeOptions(w http.ResponseWriter, r *http.Request) (models.CommentTreeOptions, bool) {
+    opts := models.DefaultCommentTreeOptions
+    query := r.URL.Query()
+    
+    if depth := query.Get("depth"); depth != "" {
+        d, err := strconv.Atoi(depth)
+        if err != nil || d < 0 || d > maxCommentDepth {
+            http.Error(w, "Depth must be between 0 and "+strconv.Itoa(maxCommentDepth), http.StatusBadRequest)
+            return opts, false
+        }
+        opts.MaxDepth = d
+    }
+    if replies := query.Get("replies"); replies != "" {
+        n, err := strconv.Atoi(replies)
+        if err != nil || n <= 0 {
+            http.Error(w, "Invalid replies count", http.StatusBadRequest)
+            return opts, false
+        }
+        if n > maxCommentPageSize {
+            n = maxCommentPageSize
+        }
+        opts.PageSize = n
+    }
+    return opts, true
+}
@@ -0,0 +1,98 @@
+// backend/controllers/search.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/backend/models"
+)
+
+const (
+	// Search results per page unless the client asks for fewer
+	defaultSearchPageSize = 20
+
+	// Largest search page a client may request
+	maxSearchPageSize = 50
+)
+
+type SearchController struct {
+	DB *sql.DB
+}
+
+// Search finds posts, comments and the viewer's own messages matching a
+// query. viewerID is 0 for anonymous visitors, who never see messages.
+func (c *SearchController) Search(w http.ResponseWriter, r *http.Request, viewerID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.SearchFilter{
+		Query:        query.Get("q"),
+		CategorySlug: query.Get("category"),
+		ViewerID:     viewerID,
+		Limit:        defaultSearchPageSize,
+	}
+
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		filter.Limit = l
+	}
+	if filter.Limit > maxSearchPageSize {
+		filter.Limit = maxSearchPageSize
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		var err error
+		if filter.Offset, err = strconv.Atoi(offset); err != nil || filter.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		if filter.Offset > models.MaxSearchOffset {
+			http.Error(w, fmt.Sprintf("Offset can be at most %d", models.MaxSearchOffset), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if types := query.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			switch t {
+			case models.SearchPosts, models.SearchComments, models.SearchMessages:
+				filter.Types = append(filter.Types, t)
+			default:
+				http.Error(w, "Type must be post, comment or message", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if author := query.Get("author"); author != "" {
+		authorID, err := strconv.Atoi(author)
+		if err != nil || authorID <= 0 {
+			http.Error(w, "Invalid author ID", http.StatusBadRequest)
+			return
+		}
+		filter.AuthorID = authorID
+	}
+
+	page, err := models.Search(c.DB, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEmptySearch):
+			http.Error(w, "Search query is required", http.StatusBadRequest)
+		default:
+			http.Error(w, "Error searching", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,148 @@
+// backend/controllers/session.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"forum/backend/models"
+	"forum/backend/websocket"
+)
+
+type SessionController struct {
+	DB  *sql.DB
+	Hub *websocket.Hub
+}
+
+// SessionResponse describes one of the user's sessions without exposing the session secret
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions lists the current user's active sessions
+func (c *SessionController) GetSessions(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions, err := models.GetSessionsByUserID(c.DB, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving sessions", http.StatusInternalServerError)
+		return
+	}
+
+	currentID := currentSessionID(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.PublicID(),
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID == currentID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeSession logs out one of the current user's sessions
+func (c *SessionController) RevokeSession(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	publicID := r.URL.Query().Get("id")
+	if publicID == "" {
+		http.Error(w, "Session ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Look the session up among the user's own sessions so nobody can
+	// revoke a session that isn't theirs
+	sessions, err := models.GetSessionsByUserID(c.DB, userID)
+	if err != nil {
+		http.Error(w, "Error retrieving sessions", http.StatusInternalServerError)
+		return
+	}
+
+	var target *models.Session
+	for i := range sessions {
+		if sessions[i].PublicID() == publicID {
+			target = &sessions[i]
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if err := models.DeleteSession(c.DB, target.ID); err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+	c.Hub.DisconnectSession(target.ID)
+	log.Printf("User %d revoked session %s", userID, publicID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions logs out every session of the current user except this one
+func (c *SessionController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionIDs, err := models.DeleteOtherSessions(c.DB, userID, currentSessionID(r))
+	if err != nil {
+		http.Error(w, "Error revoking sessions", http.StatusInternalServerError)
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		c.Hub.DisconnectSession(sessionID)
+	}
+	log.Printf("User %d revoked %d other sessions", userID, len(sessionIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"revoked": len(sessionIDs)})
+}
+
+// currentSessionID returns the session ID the request was authenticated with
+func currentSessionID(r *http.Request) string {
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// clientIP returns the address the request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
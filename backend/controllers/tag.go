@@ -0,0 +1,152 @@
+// backend/controllers/tag.go
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/backend/models"
+)
+
+// Tag autocomplete page sizes
+const (
+	defaultTagPageSize = 20
+	maxTagPageSize     = 100
+)
+
+type TagController struct {
+	DB *sql.DB
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagsRequest struct {
+	SourceID int `json:"sourceId"`
+	TargetID int `json:"targetId"`
+}
+
+// GetTags lists tags with their usage counts, most used first. With q set it
+// lists the tags starting with it, for autocomplete.
+func (c *TagController) GetTags(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultTagPageSize
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxTagPageSize {
+		limit = maxTagPageSize
+	}
+
+	tags, err := models.GetTags(c.DB, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		http.Error(w, "Error retrieving tags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// RenameTag renames a tag on every post; admins only
+func (c *TagController) RenameTag(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdmin(w, c.DB, userID, "Only admins can manage tags") {
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RenameTagRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	name := models.NormalizeTag(req.Name)
+	if name == "" || len([]rune(name)) > models.MaxTagLength {
+		http.Error(w, fmt.Sprintf("Tags must be 1 to %d letters, digits, dashes or dots", models.MaxTagLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RenameTag(c.DB, id, name); err != nil {
+		writeTagError(w, err)
+		return
+	}
+	log.Printf("User %d renamed tag %d to %s", userID, id, name)
+
+	c.writeTag(w, id)
+}
+
+// MergeTags moves every post from one tag to another and removes the first;
+// admins only
+func (c *TagController) MergeTags(w http.ResponseWriter, r *http.Request, userID int) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireAdmin(w, c.DB, userID, "Only admins can manage tags") {
+		return
+	}
+
+	var req MergeTagsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.SourceID == req.TargetID {
+		http.Error(w, "Can't merge a tag into itself", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.MergeTags(c.DB, req.SourceID, req.TargetID); err != nil {
+		writeTagError(w, err)
+		return
+	}
+	log.Printf("User %d merged tag %d into %d", userID, req.SourceID, req.TargetID)
+
+	c.writeTag(w, req.TargetID)
+}
+
+// writeTag responds with a tag and its usage count
+func (c *TagController) writeTag(w http.ResponseWriter, id int) {
+	tag, err := models.GetTagByID(c.DB, id)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// writeTagError maps a tag model error to a response
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrTagNotFound):
+		http.Error(w, "Tag not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrTagExists):
+		http.Error(w, "A tag with this name already exists; merge them instead", http.StatusConflict)
+	default:
+		http.Error(w, "Error saving tag", http.StatusInternalServerError)
+	}
+}
@@ -4,6 +4,10 @@ package controllers
 import (
     "database/sql"
     "encoding/json"
+    "image"
+    _ "image/gif"
+    _ "image/jpeg"
+    _ "image/png"
     "io"
     "net/http"
     "os"
@@ -12,6 +16,8 @@ import (
     "strings"
     "time"
     
+    "forum/backend/models"
+    
     "github.com/gofrs/uuid"
 )
 
@@ -19,24 +25,54 @@ const (
     MaxUploadSize = 10 * 1024 * 1024 // 10MB
     ImageDir      = "./frontend/uploads/images/"
     AvatarDir     = "./frontend/uploads/avatars/"
+    FileDir       = "./frontend/uploads/files/"
 )
 
+// imageTypes maps the image types that can be uploaded, as sniffed by
+// http.DetectContentType, to the extension they are stored with
+var imageTypes = map[string]string{
+    "image/png":  ".png",
+    "image/jpeg": ".jpg",
+    "image/gif":  ".gif",
+    "image/webp": ".webp",
+}
+
+// fileTypes maps every type that can be attached to the extension it is
+// stored with; anything else, notably HTML, is refused
+var fileTypes = map[string]string{
+    "image/png":                 ".png",
+    "image/jpeg":                ".jpg",
+    "image/gif":                 ".gif",
+    "image/webp":                ".webp",
+    "application/pdf":           ".pdf",
+    "application/zip":           ".zip",
+    "text/plain; charset=utf-8": ".txt",
+}
+
 type UploadController struct {
     DB *sql.DB
 }
 
+// UploadResponse describes a stored file. Uploads that can be attached to
+// posts and comments also have an ID and their metadata.
 type UploadResponse struct {
+    ID       int    `json:"id,omitempty"`
     Filename string `json:"filename"`
     URL      string `json:"url"`
+    Type     string `json:"type,omitempty"`
+    Size     int64  `json:"size,omitempty"`
+    Width    int    `json:"width,omitempty"`
+    Height   int    `json:"height,omitempty"`
 }
 
 // Initialize upload directories
 func (c *UploadController) Init() {
     os.MkdirAll(ImageDir, os.ModePerm)
     os.MkdirAll(AvatarDir, os.ModePerm)
+    os.MkdirAll(FileDir, os.ModePerm)
 }
 
-// UploadImage handles image uploads for messages
+// UploadImage handles image uploads for messages, posts and comments
 func (c *UploadController) UploadImage(w http.ResponseWriter, r *http.Request, userID int) {
     // Only allow POST method
     if r.Method != http.MethodPost {
@@ -44,6 +80,26 @@ func (c *UploadController) UploadImage(w http.ResponseWriter, r *http.Request, u
         return
     }
     
+    c.saveAttachment(w, r, userID, "image", imageTypes, ImageDir, "/uploads/images/")
+}
+
+// UploadFile handles file uploads to attach to posts and comments. Only
+// images and the document types in fileTypes are accepted.
+func (c *UploadController) UploadFile(w http.ResponseWriter, r *http.Request, userID int) {
+    // Only allow POST method
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    
+    c.saveAttachment(w, r, userID, "file", fileTypes, FileDir, "/uploads/files/")
+}
+
+// saveAttachment stores the file in a form field under dir and records it as
+// an attachment the user can add to a post or comment. The file's type is
+// sniffed from its contents rather than trusted from the client, and decides
+// its extension.
+func (c *UploadController) saveAttachment(w http.ResponseWriter, r *http.Request, userID int, field string, types map[string]string, dir, urlPath string) {
     // Parse multipart form with max size limit
     r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
     if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
@@ -52,7 +108,7 @@ func (c *UploadController) UploadImage(w http.ResponseWriter, r *http.Request, u
     }
     
     // Get uploaded file
-    file, header, err := r.FormFile("image")
+    file, header, err := r.FormFile(field)
     if err != nil {
         http.Error(w, "Invalid file", http.StatusBadRequest)
         return
@@ -60,23 +116,53 @@ func (c *UploadController) UploadImage(w http.ResponseWriter, r *http.Request, u
     defer file.Close()
     
     // Validate file type
-    contentType := header.Header.Get("Content-Type")
-    if !strings.HasPrefix(contentType, "image/") {
-        http.Error(w, "File is not an image", http.StatusBadRequest)
+    head := make([]byte, 512)
+    n, err := io.ReadFull(file, head)
+    if err != nil && err != io.ErrUnexpectedEOF {
+        http.Error(w, "Invalid file", http.StatusBadRequest)
+        return
+    }
+    contentType := http.DetectContentType(head[:n])
+    ext, ok := types[contentType]
+    if !ok {
+        http.Error(w, "File type not allowed", http.StatusBadRequest)
+        return
+    }
+    
+    attachment := models.Attachment{
+        UserID:   userID,
+        Filename: filepath.Base(header.Filename),
+        Type:     strings.TrimSuffix(contentType, "; charset=utf-8"),
+        Size:     header.Size,
+    }
+    
+    // Record image dimensions where the format can be decoded
+    if strings.HasPrefix(contentType, "image/") {
+        if _, err := file.Seek(0, io.SeekStart); err != nil {
+            http.Error(w, "Error reading file", http.StatusInternalServerError)
+            return
+        }
+        if config, _, err := image.DecodeConfig(file); err == nil {
+            attachment.Width = config.Width
+            attachment.Height = config.Height
+        }
+    }
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
+        http.Error(w, "Error reading file", http.StatusInternalServerError)
         return
     }
     
     // Generate unique filename
-    ext := filepath.Ext(header.Filename)
     uuid, err := uuid.NewV4()
     if err != nil {
         http.Error(w, "Error generating filename", http.StatusInternalServerError)
         return
     }
     filename := uuid.String() + ext
+    attachment.URL = urlPath + filename
     
     // Create destination file
-    dst, err := os.Create(filepath.Join(ImageDir, filename))
+    dst, err := os.Create(filepath.Join(dir, filename))
     if err != nil {
         http.Error(w, "Error saving file", http.StatusInternalServerError)
         return
@@ -89,10 +175,21 @@ func (c *UploadController) UploadImage(w http.ResponseWriter, r *http.Request, u
         return
     }
     
+    attachmentID, err := models.CreateAttachment(c.DB, attachment)
+    if err != nil {
+        http.Error(w, "Error saving file", http.StatusInternalServerError)
+        return
+    }
+    
     // Return success response
     response := UploadResponse{
+        ID:       int(attachmentID),
         Filename: filename,
-        URL:      "/uploads/images/" + filename,
+        URL:      attachment.URL,
+        Type:     attachment.Type,
+        Size:     attachment.Size,
+        Width:    attachment.Width,
+        Height:   attachment.Height,
     }
     
     w.Header().Set("Content-Type", "application/json")
@@ -4,8 +4,12 @@ package database
 import (
 	"database/sql"
 	"log"
+	"strings"
 	"time"
 
+	"forum/backend/markdown"
+	"forum/backend/models"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -45,6 +49,7 @@ func createTables(db *sql.DB) {
         last_name TEXT NOT NULL,
         email TEXT UNIQUE NOT NULL,
         password TEXT NOT NULL,
+        role TEXT NOT NULL DEFAULT 'user',
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
     );`
 
@@ -55,9 +60,19 @@ func createTables(db *sql.DB) {
         user_id INTEGER NOT NULL,
         title TEXT NOT NULL,
         content TEXT NOT NULL,
+        content_html TEXT NOT NULL DEFAULT '',
         category TEXT NOT NULL,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-        FOREIGN KEY (user_id) REFERENCES users (id)
+        edited_by INTEGER,
+        edited_at TIMESTAMP,
+        deleted_at TIMESTAMP,
+        status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published')),
+        publish_at TIMESTAMP,
+        locked_at TIMESTAMP,
+        archived_at TIMESTAMP,
+        view_count INTEGER NOT NULL DEFAULT 0,
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        FOREIGN KEY (edited_by) REFERENCES users (id)
     );`
 
 	// Comments table
@@ -66,10 +81,13 @@ func createTables(db *sql.DB) {
         id INTEGER PRIMARY KEY AUTOINCREMENT,
         post_id INTEGER NOT NULL,
         user_id INTEGER NOT NULL,
+        parent_id INTEGER,
         content TEXT NOT NULL,
+        content_html TEXT NOT NULL DEFAULT '',
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
         FOREIGN KEY (post_id) REFERENCES posts (id),
-        FOREIGN KEY (user_id) REFERENCES users (id)
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        FOREIGN KEY (parent_id) REFERENCES comments (id)
     );`
 
 	// Messages table
@@ -79,8 +97,10 @@ func createTables(db *sql.DB) {
 		sender_id INTEGER NOT NULL,
 		receiver_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
+		content_html TEXT NOT NULL DEFAULT '',
 		image_url TEXT DEFAULT '',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		read_at TIMESTAMP,
 		FOREIGN KEY (sender_id) REFERENCES users (id),
 		FOREIGN KEY (receiver_id) REFERENCES users (id)
 	);`
@@ -91,6 +111,10 @@ func createTables(db *sql.DB) {
         id TEXT PRIMARY KEY,
         user_id INTEGER NOT NULL,
         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        user_agent TEXT DEFAULT '',
+        ip_address TEXT DEFAULT '',
         FOREIGN KEY (user_id) REFERENCES users (id)
     );`
 
@@ -104,6 +128,211 @@ func createTables(db *sql.DB) {
         FOREIGN KEY (user_id) REFERENCES users (id)
     );`
 
+	// Post revisions table, holding every earlier version of an edited post
+	createPostRevisionsTable := `
+    CREATE TABLE IF NOT EXISTS post_revisions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        post_id INTEGER NOT NULL,
+        title TEXT NOT NULL,
+        content TEXT NOT NULL,
+        category TEXT NOT NULL,
+        editor_id INTEGER NOT NULL,
+        created_at TIMESTAMP NOT NULL,
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (editor_id) REFERENCES users (id)
+    );`
+
+	// Pinned posts, in order, on the front page (category 0) or a category
+	createPostPinsTable := `
+    CREATE TABLE IF NOT EXISTS post_pins (
+        post_id INTEGER NOT NULL,
+        category_id INTEGER NOT NULL DEFAULT 0,
+        position INTEGER NOT NULL DEFAULT 0,
+        pinned_by INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (post_id, category_id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (pinned_by) REFERENCES users (id)
+    );`
+
+	// Categories table
+	createCategoriesTable := `
+    CREATE TABLE IF NOT EXISTS categories (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        slug TEXT UNIQUE NOT NULL,
+        name TEXT NOT NULL,
+        description TEXT DEFAULT '',
+        sort_order INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+
+	// Post categories join table
+	createPostCategoriesTable := `
+    CREATE TABLE IF NOT EXISTS post_categories (
+        post_id INTEGER NOT NULL,
+        category_id INTEGER NOT NULL,
+        PRIMARY KEY (post_id, category_id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (category_id) REFERENCES categories (id)
+    );`
+
+	// Tags table; names are normalized, see models.NormalizeTag
+	createTagsTable := `
+    CREATE TABLE IF NOT EXISTS tags (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT UNIQUE NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );`
+
+	// Post tags join table
+	createPostTagsTable := `
+    CREATE TABLE IF NOT EXISTS post_tags (
+        post_id INTEGER NOT NULL,
+        tag_id INTEGER NOT NULL,
+        PRIMARY KEY (post_id, tag_id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (tag_id) REFERENCES tags (id)
+    );`
+
+	// Polls attached to posts, at most one per post
+	createPollsTable := `
+    CREATE TABLE IF NOT EXISTS polls (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        post_id INTEGER UNIQUE NOT NULL,
+        question TEXT NOT NULL,
+        multiple BOOLEAN NOT NULL DEFAULT 0,
+        anonymous BOOLEAN NOT NULL DEFAULT 0,
+        closes_at TIMESTAMP,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (post_id) REFERENCES posts (id)
+    );`
+
+	// Poll options, in display order
+	createPollOptionsTable := `
+    CREATE TABLE IF NOT EXISTS poll_options (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        poll_id INTEGER NOT NULL,
+        position INTEGER NOT NULL,
+        text TEXT NOT NULL,
+        FOREIGN KEY (poll_id) REFERENCES polls (id)
+    );`
+
+	// Poll votes; a user's ballot is their votes in one poll
+	createPollVotesTable := `
+    CREATE TABLE IF NOT EXISTS poll_votes (
+        poll_id INTEGER NOT NULL,
+        option_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (option_id, user_id),
+        FOREIGN KEY (poll_id) REFERENCES polls (id),
+        FOREIGN KEY (option_id) REFERENCES poll_options (id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );`
+
+	// Uploaded files; each is attached to at most one post or comment by
+	// whoever uploaded it
+	createAttachmentsTable := `
+    CREATE TABLE IF NOT EXISTS attachments (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        user_id INTEGER NOT NULL,
+        post_id INTEGER,
+        comment_id INTEGER,
+        filename TEXT NOT NULL,
+        url TEXT NOT NULL,
+        content_type TEXT NOT NULL,
+        size INTEGER NOT NULL,
+        width INTEGER NOT NULL DEFAULT 0,
+        height INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (comment_id) REFERENCES comments (id)
+    );`
+
+	// Who has viewed each post, so a viewer is only counted once; viewer is
+	// "user:<id>" or "visitor:<cookie>" for anonymous visitors
+	createPostViewsTable := `
+    CREATE TABLE IF NOT EXISTS post_views (
+        post_id INTEGER NOT NULL,
+        viewer TEXT NOT NULL,
+        viewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (post_id, viewer),
+        FOREIGN KEY (post_id) REFERENCES posts (id)
+    );`
+
+	// Ranking scores of published posts, recomputed periodically rather than
+	// on every feed request
+	createPostScoresTable := `
+    CREATE TABLE IF NOT EXISTS post_scores (
+        post_id INTEGER PRIMARY KEY,
+        score REAL NOT NULL,
+        hot REAL NOT NULL,
+        refreshed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (post_id) REFERENCES posts (id)
+    );`
+
+	// Posts and comments users saved to read later, each optionally filed in
+	// one of the user's folders
+	createBookmarksTable := `
+    CREATE TABLE IF NOT EXISTS bookmarks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        user_id INTEGER NOT NULL,
+        target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment')),
+        target_id INTEGER NOT NULL,
+        folder TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (user_id, target_type, target_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );`
+
+	// Follow choices users made by hand. Authors and commenters follow a post
+	// without a row here; following = 0 records that they unfollowed it.
+	createPostFollowsTable := `
+    CREATE TABLE IF NOT EXISTS post_follows (
+        post_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        following INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (post_id, user_id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );`
+
+	// Notifications kept for users to read later, such as a new comment on a
+	// post they follow by actor_id
+	createNotificationsTable := `
+    CREATE TABLE IF NOT EXISTS notifications (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        user_id INTEGER NOT NULL,
+        type TEXT NOT NULL CHECK (type IN ('new_comment')),
+        post_id INTEGER NOT NULL,
+        comment_id INTEGER,
+        actor_id INTEGER NOT NULL,
+        read_at TIMESTAMP,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        FOREIGN KEY (post_id) REFERENCES posts (id),
+        FOREIGN KEY (comment_id) REFERENCES comments (id),
+        FOREIGN KEY (actor_id) REFERENCES users (id)
+    );`
+
+	// Reactions table, one like or dislike per user per post or comment
+	createReactionsTable := `
+    CREATE TABLE IF NOT EXISTS reactions (
+        user_id INTEGER NOT NULL,
+        target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment')),
+        target_id INTEGER NOT NULL,
+        reaction TEXT NOT NULL CHECK (reaction IN ('like', 'dislike')),
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id, target_type, target_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );`
+
+	// Categories used to be free text on each post; convert them once, when
+	// the categories table is first created
+	convertCategories := !tableExists(db, "categories")
+
 	// Execute all creation queries
 	_, err := db.Exec(createUsersTable)
 	if err != nil {
@@ -135,9 +364,95 @@ func createTables(db *sql.DB) {
 		log.Fatal(err)
 	}
 
+	_, err = db.Exec(createPostRevisionsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createCategoriesTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostCategoriesTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createTagsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostTagsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createReactionsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostPinsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createAttachmentsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPollsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPollOptionsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPollVotesTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostViewsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostScoresTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createBookmarksTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createPostFollowsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(createNotificationsTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrateTables(db)
+	if convertCategories {
+		migrateCategories(db)
+	}
+
 	// Create indexes for faster queries
 	createMessagesIndex := `
-	CREATE INDEX IF NOT EXISTS idx_messages_sender_receiver ON messages (sender_id, receiver_id);
+	DROP INDEX IF EXISTS idx_messages_sender_receiver;
+	CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages (sender_id, receiver_id, id);
 	`
 	_, err = db.Exec(createMessagesIndex)
 	if err != nil {
@@ -146,17 +461,316 @@ func createTables(db *sql.DB) {
 
 	createPostsIndex := `
 	CREATE INDEX IF NOT EXISTS idx_posts_user ON posts (user_id);
+	CREATE INDEX IF NOT EXISTS idx_posts_created ON posts (created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_posts_publish ON posts (status, publish_at);
 	`
 	_, err = db.Exec(createPostsIndex)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	createPostRevisionsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_post_revisions_post ON post_revisions (post_id, id);
+	`
+	_, err = db.Exec(createPostRevisionsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createPostCategoriesIndex := `
+	CREATE INDEX IF NOT EXISTS idx_post_categories_category ON post_categories (category_id, post_id);
+	`
+	_, err = db.Exec(createPostCategoriesIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createPostTagsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_post_tags_tag ON post_tags (tag_id, post_id);
+	`
+	_, err = db.Exec(createPostTagsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createAttachmentsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_attachments_post ON attachments (post_id);
+	CREATE INDEX IF NOT EXISTS idx_attachments_comment ON attachments (comment_id);
+	`
+	_, err = db.Exec(createAttachmentsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createPollsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_poll_options_poll ON poll_options (poll_id, position);
+	CREATE INDEX IF NOT EXISTS idx_poll_votes_ballot ON poll_votes (poll_id, user_id);
+	`
+	_, err = db.Exec(createPollsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createBookmarksIndex := `
+	CREATE INDEX IF NOT EXISTS idx_bookmarks_user ON bookmarks (user_id, folder, id);
+	`
+	_, err = db.Exec(createBookmarksIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createNotificationsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, id);
+	`
+	_, err = db.Exec(createNotificationsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createReactionsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_reactions_target ON reactions (target_type, target_id, reaction);
+	`
+	_, err = db.Exec(createReactionsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createPostPinsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_post_pins_scope ON post_pins (category_id, position);
+	`
+	_, err = db.Exec(createPostPinsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	createCommentsIndex := `
 	CREATE INDEX IF NOT EXISTS idx_comments_post ON comments (post_id);
+	CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments (post_id, parent_id, id);
+	CREATE INDEX IF NOT EXISTS idx_comments_user ON comments (user_id, post_id);
 	`
 	_, err = db.Exec(createCommentsIndex)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	createSessionsIndex := `
+	CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions (expires_at);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
+	`
+	_, err = db.Exec(createSessionsIndex)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	createSearchTables(db)
+}
+
+// migrateTables brings tables created by older versions up to date
+func migrateTables(db *sql.DB) {
+	// Sessions gained expiry tracking; give existing sessions the default
+	// 7 day lifetime measured from when they were created
+	addColumn(db, "sessions", "last_seen_at", "TIMESTAMP")
+	if addColumn(db, "sessions", "expires_at", "TIMESTAMP") {
+		_, err := db.Exec(`UPDATE sessions
+			SET last_seen_at = created_at, expires_at = datetime(created_at, '+7 days')
+			WHERE expires_at IS NULL`)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Sessions record the device they were created from
+	addColumn(db, "sessions", "user_agent", "TEXT DEFAULT ''")
+	addColumn(db, "sessions", "ip_address", "TEXT DEFAULT ''")
+
+	// Messages track when the receiver read them
+	addColumn(db, "messages", "read_at", "TIMESTAMP")
+
+	// Users have a role; moderators and admins are promoted by hand
+	addColumn(db, "users", "role", "TEXT NOT NULL DEFAULT 'user'")
+
+	// Posts can be edited and deleted
+	addColumn(db, "posts", "edited_by", "INTEGER REFERENCES users (id)")
+	addColumn(db, "posts", "edited_at", "TIMESTAMP")
+	addColumn(db, "posts", "deleted_at", "TIMESTAMP")
+
+	// Comments can reply to other comments
+	addColumn(db, "comments", "parent_id", "INTEGER REFERENCES comments (id)")
+
+	// Posts can be saved as drafts and scheduled
+	addColumn(db, "posts", "status", "TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published'))")
+	addColumn(db, "posts", "publish_at", "TIMESTAMP")
+
+	// Moderators can lock and archive posts
+	addColumn(db, "posts", "locked_at", "TIMESTAMP")
+	addColumn(db, "posts", "archived_at", "TIMESTAMP")
+
+	// Posts count their distinct viewers
+	addColumn(db, "posts", "view_count", "INTEGER NOT NULL DEFAULT 0")
+
+	// Content is written in Markdown and stored rendered as well
+	for _, table := range []string{"posts", "comments", "messages"} {
+		if addColumn(db, table, "content_html", "TEXT NOT NULL DEFAULT ''") {
+			renderContent(db, table)
+		}
+	}
+}
+
+// renderContent fills in the rendered HTML of every row of a table from its
+// Markdown content
+func renderContent(db *sql.DB, table string) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, content FROM ` + table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rendered := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			log.Fatal(err)
+		}
+		rendered[id] = markdown.Render(content)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	rows.Close()
+
+	for id, html := range rendered {
+		if _, err := tx.Exec(`UPDATE `+table+` SET content_html = ? WHERE id = ?`, html, id); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Rendered content of %d %s", len(rendered), table)
+}
+
+// defaultCategories are offered on a fresh install, in display order
+var defaultCategories = []string{"General", "Technology", "Sports", "Gaming", "Movies", "Music", "Other"}
+
+// migrateCategories seeds the default categories and files every existing
+// post under a category named after its old free text category
+func migrateCategories(db *sql.DB) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	slugs := make(map[string]int64)
+	addCategory := func(name string) int64 {
+		slug := models.Slugify(name)
+		if slug == "" {
+			slug = "uncategorized"
+		}
+		if id, ok := slugs[slug]; ok {
+			return id
+		}
+		result, err := tx.Exec(`INSERT INTO categories (slug, name, sort_order) VALUES (?, ?, ?)`,
+			slug, strings.TrimSpace(name), len(slugs)+1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			log.Fatal(err)
+		}
+		slugs[slug] = id
+		return id
+	}
+
+	for _, name := range defaultCategories {
+		addCategory(name)
+	}
+
+	rows, err := tx.Query(`SELECT id, category FROM posts`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	links := make(map[int64]int64)
+	for rows.Next() {
+		var postID int64
+		var name string
+		if err := rows.Scan(&postID, &name); err != nil {
+			log.Fatal(err)
+		}
+		links[postID] = addCategory(name)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	rows.Close()
+
+	for postID, categoryID := range links {
+		_, err := tx.Exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, postID, categoryID)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Converted categories of %d posts", len(links))
+}
+
+// tableExists reports whether a table has been created
+func tableExists(db *sql.DB, table string) bool {
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return true
+}
+
+// addColumn adds a column to a table if it does not exist yet and reports
+// whether it was added
+func addColumn(db *sql.DB, table, column, definition string) bool {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			log.Fatal(err)
+		}
+		if name == column {
+			return false
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	rows.Close()
+
+	log.Printf("Adding column %s.%s", table, column)
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return true
 }
@@ -0,0 +1,90 @@
+// backend/database/search.go
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// searchIndexes lists each FTS5 index with the table it mirrors and the
+// columns it indexes
+var searchIndexes = []struct {
+	table   string
+	columns []string
+}{
+	{"posts", []string{"title", "content"}},
+	{"comments", []string{"content"}},
+	{"messages", []string{"content"}},
+}
+
+// createSearchTables sets up the full-text indexes used by search, kept in
+// sync with their tables by triggers. FTS5 is only compiled into the SQLite
+// driver when building with -tags sqlite_fts5 (see the Makefile), so the
+// server refuses to start without it rather than run with search broken.
+func createSearchTables(db *sql.DB) {
+	if !fts5Available(db) {
+		log.Fatal("SQLite was built without FTS5, which search needs: build with -tags sqlite_fts5, or run make")
+	}
+
+	for _, index := range searchIndexes {
+		fts := index.table + "_fts"
+		columns := strings.Join(index.columns, ", ")
+		newValues := "new." + strings.Join(index.columns, ", new.")
+		oldValues := "old." + strings.Join(index.columns, ", old.")
+
+		// The index missed any writes made while its triggers were missing
+		stale := !triggerExists(db, fts+"_insert")
+
+		statements := []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS ` + fts + ` USING fts5(` + columns + `,
+				content='` + index.table + `', content_rowid='id', tokenize='porter unicode61')`,
+			`CREATE TRIGGER IF NOT EXISTS ` + fts + `_insert AFTER INSERT ON ` + index.table + ` BEGIN
+				INSERT INTO ` + fts + ` (rowid, ` + columns + `) VALUES (new.id, ` + newValues + `);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ` + fts + `_delete AFTER DELETE ON ` + index.table + ` BEGIN
+				INSERT INTO ` + fts + ` (` + fts + `, rowid, ` + columns + `) VALUES ('delete', old.id, ` + oldValues + `);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS ` + fts + `_update AFTER UPDATE OF ` + columns + ` ON ` + index.table + ` BEGIN
+				INSERT INTO ` + fts + ` (` + fts + `, rowid, ` + columns + `) VALUES ('delete', old.id, ` + oldValues + `);
+				INSERT INTO ` + fts + ` (rowid, ` + columns + `) VALUES (new.id, ` + newValues + `);
+			END`,
+		}
+		for _, statement := range statements {
+			if _, err := db.Exec(statement); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if stale {
+			log.Printf("Rebuilding search index %s", fts)
+			_, err := db.Exec(`INSERT INTO ` + fts + ` (` + fts + `) VALUES ('rebuild')`)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// fts5Available reports whether the SQLite driver was built with FTS5
+func fts5Available(db *sql.DB) bool {
+	var enabled bool
+	err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return enabled
+}
+
+// triggerExists reports whether a trigger has been created
+func triggerExists(db *sql.DB, trigger string) bool {
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'trigger' AND name = ?`, trigger).Scan(&name)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+// backend/markdown/html.go
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// node is an element of the rendered document, or text when tag is empty
+type node struct {
+	tag      string
+	attrs    []attr
+	text     string
+	children []*node
+}
+
+type attr struct {
+	name, value string
+}
+
+func element(tag string, children ...*node) *node {
+	return &node{tag: tag, children: children}
+}
+
+func textNode(text string) *node {
+	return &node{text: text}
+}
+
+// allowedTags lists the only elements written out and the attributes each
+// may keep. Anything else is dropped, keeping its text.
+var allowedTags = map[string]map[string]bool{
+	"p":          nil,
+	"br":         nil,
+	"hr":         nil,
+	"em":         nil,
+	"strong":     nil,
+	"code":       {"class": true},
+	"pre":        nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start": true},
+	"li":         nil,
+	"a":          {"href": true, "title": true, "rel": true},
+}
+
+// Elements written on lines of their own
+var blockTags = map[string]bool{
+	"p": true, "hr": true, "pre": true, "blockquote": true, "ul": true, "ol": true, "li": true,
+}
+
+// Elements whose children start on a new line
+var containerTags = map[string]bool{
+	"blockquote": true, "ul": true, "ol": true,
+}
+
+// Link schemes allowed in href; links without a scheme are relative
+var allowedSchemes = map[string]bool{
+	"":       true,
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// writeNode writes n as HTML, keeping only allowed tags and attributes
+func writeNode(b *strings.Builder, n *node) {
+	if n.tag == "" {
+		b.WriteString(html.EscapeString(n.text))
+		return
+	}
+
+	allowed, ok := allowedTags[n.tag]
+	var attrs []attr
+	for _, a := range n.attrs {
+		if allowed[a.name] && safeAttr(n.tag, a) {
+			attrs = append(attrs, a)
+		}
+	}
+
+	// A link whose target was dropped is just its text
+	if n.tag == "a" && !hasAttr(attrs, "href") {
+		ok = false
+	}
+	if !ok {
+		for _, child := range n.children {
+			writeNode(b, child)
+		}
+		return
+	}
+
+	b.WriteString("<" + n.tag)
+	for _, a := range attrs {
+		b.WriteString(" " + a.name + `="` + html.EscapeString(a.value) + `"`)
+	}
+
+	if n.tag == "br" || n.tag == "hr" {
+		b.WriteString(" />")
+		if n.tag == "hr" {
+			b.WriteByte('\n')
+		}
+		return
+	}
+
+	b.WriteByte('>')
+	if containerTags[n.tag] {
+		b.WriteByte('\n')
+	}
+	for _, child := range n.children {
+		writeNode(b, child)
+	}
+	b.WriteString("</" + n.tag + ">")
+	if blockTags[n.tag] {
+		b.WriteByte('\n')
+	}
+}
+
+// safeAttr checks the value of an allowed attribute
+func safeAttr(tag string, a attr) bool {
+	switch a.name {
+	case "href":
+		return safeURL(a.value)
+	case "class":
+		// Only the language of a code block
+		lang := strings.TrimPrefix(a.value, "language-")
+		return tag == "code" && lang != a.value && lang != "" && strings.Trim(lang, identChars) == ""
+	case "start":
+		return a.value != "" && strings.Trim(a.value, "0123456789") == ""
+	case "rel":
+		return a.value == "nofollow"
+	}
+	return true
+}
+
+const identChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+#.-"
+
+// safeURL reports whether a link target uses an allowed scheme. Targets that
+// don't parse, such as ones hiding a scheme behind control characters, are
+// refused.
+func safeURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return allowedSchemes[strings.ToLower(u.Scheme)]
+}
+
+func hasAttr(attrs []attr, name string) bool {
+	for _, a := range attrs {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
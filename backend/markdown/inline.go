@@ -0,0 +1,498 @@
+// backend/markdown/inline.go
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// An absolute URI in an autolink, such as <https://example.com>
+	autolinkURI = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*$`)
+
+	// An email address in an autolink, such as <someone@example.com>
+	autolinkEmail = regexp.MustCompile(`^[A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+)
+
+// piece is an inline node, or a run of emphasis characters or a [ that may
+// still pair up with a later one. Pieces form a doubly linked list.
+type piece struct {
+	node *node
+
+	// '*', '_' or '[' while the piece may still pair up, otherwise 0. The
+	// node holds the characters as text in case it never does.
+	delim byte
+
+	// Emphasis characters left in the run
+	count int
+
+	canOpen  bool
+	canClose bool
+
+	// Whether a [ may still start a link; links can't contain links
+	active bool
+
+	prev, next *piece
+}
+
+type inlineParser struct {
+	src  string
+	pos  int
+	text []byte
+
+	head, tail *piece
+}
+
+// parseInline parses the text of a paragraph into inline nodes
+func parseInline(src string) []*node {
+	p := &inlineParser{src: src}
+
+	for p.pos < len(src) {
+		switch c := src[p.pos]; c {
+		case '\\':
+			p.parseEscape()
+		case '`':
+			p.parseCodeSpan()
+		case '*', '_':
+			p.parseDelimiterRun()
+		case '[':
+			p.push(&piece{node: textNode("["), delim: '[', active: true})
+			p.pos++
+		case ']':
+			p.parseCloseBracket()
+		case '<':
+			p.parseAutolink()
+		case '\n':
+			p.parseLineBreak()
+		default:
+			p.text = append(p.text, c)
+			p.pos++
+		}
+	}
+
+	p.flushText()
+	p.processEmphasis(nil)
+	return p.nodes(p.head, nil)
+}
+
+// flushText turns the pending text into a node
+func (p *inlineParser) flushText() {
+	if len(p.text) > 0 {
+		p.append(&piece{node: textNode(string(p.text))})
+		p.text = p.text[:0]
+	}
+}
+
+// push adds a piece after any pending text
+func (p *inlineParser) push(piece *piece) {
+	p.flushText()
+	p.append(piece)
+}
+
+func (p *inlineParser) append(piece *piece) {
+	piece.prev = p.tail
+	if p.tail != nil {
+		p.tail.next = piece
+	} else {
+		p.head = piece
+	}
+	p.tail = piece
+}
+
+func (p *inlineParser) remove(piece *piece) {
+	if piece.prev != nil {
+		piece.prev.next = piece.next
+	} else {
+		p.head = piece.next
+	}
+	if piece.next != nil {
+		piece.next.prev = piece.prev
+	} else {
+		p.tail = piece.prev
+	}
+}
+
+// nodes collects the nodes of the pieces from first up to, but not
+// including, last
+func (p *inlineParser) nodes(first, last *piece) []*node {
+	var nodes []*node
+	for piece := first; piece != nil && piece != last; piece = piece.next {
+		nodes = append(nodes, piece.node)
+	}
+	return nodes
+}
+
+// parseEscape handles a backslash, which makes the punctuation after it
+// literal or ends the line with a hard break
+func (p *inlineParser) parseEscape() {
+	if p.pos+1 < len(p.src) {
+		next := p.src[p.pos+1]
+		if next == '\n' {
+			p.push(&piece{node: element("br")})
+			p.pos += 2
+			p.skipIndent()
+			return
+		}
+		if isASCIIPunct(next) {
+			p.text = append(p.text, next)
+			p.pos += 2
+			return
+		}
+	}
+	p.text = append(p.text, '\\')
+	p.pos++
+}
+
+// parseCodeSpan reads text between matching runs of backticks
+func (p *inlineParser) parseCodeSpan() {
+	n := runLength(p.src, p.pos)
+	start := p.pos + n
+
+	for i := start; i < len(p.src); {
+		if p.src[i] != '`' {
+			i++
+			continue
+		}
+		m := runLength(p.src, i)
+		if m == n {
+			code := strings.ReplaceAll(p.src[start:i], "\n", " ")
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			p.push(&piece{node: element("code", textNode(code))})
+			p.pos = i + m
+			return
+		}
+		i += m
+	}
+
+	// Unmatched backticks are literal
+	p.text = append(p.text, p.src[p.pos:start]...)
+	p.pos = start
+}
+
+// parseDelimiterRun reads a run of * or _, which may open or close emphasis
+// depending on what surrounds it
+func (p *inlineParser) parseDelimiterRun() {
+	c := p.src[p.pos]
+	n := runLength(p.src, p.pos)
+
+	before, after := ' ', ' '
+	if p.pos > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:p.pos])
+	}
+	if p.pos+n < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos+n:])
+	}
+
+	leftFlanking := !unicode.IsSpace(after) &&
+		(!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	rightFlanking := !unicode.IsSpace(before) &&
+		(!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	run := &piece{node: textNode(p.src[p.pos : p.pos+n]), delim: c, count: n}
+	if c == '*' {
+		run.canOpen = leftFlanking
+		run.canClose = rightFlanking
+	} else {
+		// Underscores inside words don't count, as in snake_case
+		run.canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		run.canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	}
+
+	p.push(run)
+	p.pos += n
+}
+
+// parseCloseBracket makes a link of the text since the last [ when a
+// destination follows
+func (p *inlineParser) parseCloseBracket() {
+	p.flushText()
+	p.pos++
+
+	var opener *piece
+	for piece := p.tail; piece != nil; piece = piece.prev {
+		if piece.delim == '[' {
+			opener = piece
+			break
+		}
+	}
+	if opener == nil {
+		p.text = append(p.text, ']')
+		return
+	}
+
+	dest, title, end, ok := parseLinkTail(p.src, p.pos)
+	if !opener.active || !ok {
+		opener.delim = 0
+		p.text = append(p.text, ']')
+		return
+	}
+
+	p.processEmphasis(opener)
+	link := element("a", p.nodes(opener.next, nil)...)
+	link.attrs = append(link.attrs, attr{"href", dest}, attr{"rel", "nofollow"})
+	if title != "" {
+		link.attrs = append(link.attrs, attr{"title", title})
+	}
+
+	opener.node = link
+	opener.delim = 0
+	opener.next = nil
+	p.tail = opener
+	p.pos = end
+
+	for piece := opener.prev; piece != nil; piece = piece.prev {
+		if piece.delim == '[' {
+			piece.active = false
+		}
+	}
+}
+
+// parseLinkTail reads the (destination "title") after a link's text,
+// starting at i. It returns where the link ends.
+func parseLinkTail(src string, i int) (dest, title string, end int, ok bool) {
+	if i >= len(src) || src[i] != '(' {
+		return "", "", 0, false
+	}
+	i = skipSpace(src, i+1)
+
+	if i < len(src) && src[i] == '<' {
+		close := strings.IndexAny(src[i+1:], "<>\n")
+		if close < 0 || src[i+1+close] != '>' {
+			return "", "", 0, false
+		}
+		dest = src[i+1 : i+1+close]
+		i += close + 2
+	} else {
+		start, depth := i, 0
+		for ; i < len(src); i++ {
+			c := src[i]
+			if c == '\\' && i+1 < len(src) && isASCIIPunct(src[i+1]) {
+				i++
+				continue
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if c <= ' ' {
+				break
+			}
+		}
+		dest = src[start:i]
+	}
+
+	// A title must be separated from the destination
+	j := skipSpace(src, i)
+	if j < len(src) && j > i && strings.IndexByte(`"'(`, src[j]) >= 0 {
+		closing := src[j]
+		if closing == '(' {
+			closing = ')'
+		}
+		k := j + 1
+		for ; k < len(src) && src[k] != closing; k++ {
+			if src[k] == '\\' && k+1 < len(src) {
+				k++
+			}
+		}
+		if k >= len(src) {
+			return "", "", 0, false
+		}
+		title = src[j+1 : k]
+		j = skipSpace(src, k+1)
+	}
+
+	if j >= len(src) || src[j] != ')' {
+		return "", "", 0, false
+	}
+	return unescape(dest), unescape(title), j + 1, true
+}
+
+// parseAutolink reads a URL or email address in angle brackets. Anything
+// else starting with < is literal text; raw HTML is not supported.
+func (p *inlineParser) parseAutolink() {
+	if end := strings.IndexAny(p.src[p.pos+1:], "<> \n"); end >= 0 && p.src[p.pos+1+end] == '>' {
+		target := p.src[p.pos+1 : p.pos+1+end]
+		href := ""
+		if autolinkURI.MatchString(target) {
+			href = target
+		} else if autolinkEmail.MatchString(target) {
+			href = "mailto:" + target
+		}
+
+		if href != "" {
+			link := element("a", textNode(target))
+			link.attrs = append(link.attrs, attr{"href", href}, attr{"rel", "nofollow"})
+			p.push(&piece{node: link})
+			p.pos += end + 2
+			return
+		}
+	}
+
+	p.text = append(p.text, '<')
+	p.pos++
+}
+
+// parseLineBreak ends a line inside a paragraph, with a hard break when the
+// line ended in two or more spaces
+func (p *inlineParser) parseLineBreak() {
+	trimmed := len(p.text)
+	for trimmed > 0 && p.text[trimmed-1] == ' ' {
+		trimmed--
+	}
+	hard := len(p.text)-trimmed >= 2
+	p.text = p.text[:trimmed]
+
+	if hard {
+		p.push(&piece{node: element("br")})
+	} else {
+		p.text = append(p.text, '\n')
+	}
+	p.pos++
+	p.skipIndent()
+}
+
+func (p *inlineParser) skipIndent() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// emphasisKey groups closers for remembering where no opener can be found
+type emphasisKey struct {
+	delim   byte
+	canOpen bool
+	mod     int
+}
+
+// processEmphasis pairs up runs of * and _ after bottom, or in the whole
+// paragraph when bottom is nil, into em and strong elements. Runs left
+// unpaired stay as text.
+func (p *inlineParser) processEmphasis(bottom *piece) {
+	first := p.head
+	if bottom != nil {
+		first = bottom.next
+	}
+
+	// Where the search for an opener stopped last time for each kind of
+	// closer; there's no point searching below it again
+	openersBottom := make(map[emphasisKey]*piece)
+
+	for closer := first; closer != nil; {
+		if (closer.delim != '*' && closer.delim != '_') || !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		key := emphasisKey{closer.delim, closer.canOpen, closer.count % 3}
+		stop, seen := openersBottom[key]
+		if !seen {
+			stop = bottom
+		}
+
+		var opener *piece
+		for candidate := closer.prev; candidate != nil && candidate != stop; candidate = candidate.prev {
+			if candidate.delim != closer.delim || !candidate.canOpen {
+				continue
+			}
+			// Runs that can both open and close only pair up when their
+			// lengths together aren't a multiple of three
+			if (candidate.canClose || closer.canOpen) &&
+				(candidate.count+closer.count)%3 == 0 &&
+				(candidate.count%3 != 0 || closer.count%3 != 0) {
+				continue
+			}
+			opener = candidate
+			break
+		}
+
+		if opener == nil {
+			openersBottom[key] = closer.prev
+			next := closer.next
+			if !closer.canOpen {
+				closer.delim = 0
+			}
+			closer = next
+			continue
+		}
+
+		use, tag := 1, "em"
+		if opener.count >= 2 && closer.count >= 2 {
+			use, tag = 2, "strong"
+		}
+
+		emphasis := &piece{node: element(tag, p.nodes(opener.next, closer)...)}
+		opener.next, emphasis.prev = emphasis, opener
+		emphasis.next, closer.prev = closer, emphasis
+
+		opener.count -= use
+		opener.node.text = opener.node.text[:opener.count]
+		if opener.count == 0 {
+			p.remove(opener)
+		}
+
+		closer.count -= use
+		closer.node.text = closer.node.text[:closer.count]
+		if closer.count == 0 {
+			next := closer.next
+			p.remove(closer)
+			closer = next
+		}
+	}
+
+	first = p.head
+	if bottom != nil {
+		first = bottom.next
+	}
+	for piece := first; piece != nil; piece = piece.next {
+		if piece.delim == '*' || piece.delim == '_' {
+			piece.delim = 0
+		}
+	}
+}
+
+// runLength counts the repeats of the character at i
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// unescape removes the backslashes escaping punctuation
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
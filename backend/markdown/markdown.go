@@ -0,0 +1,391 @@
+// backend/markdown/markdown.go
+
+// Package markdown renders the subset of CommonMark allowed in posts,
+// comments and messages: paragraphs, emphasis, code spans and blocks, links,
+// lists, block quotes and thematic breaks. Raw HTML in the source is shown as
+// text, and the output is written from an allow-list of tags and attributes,
+// so it is safe to insert into a page as is.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Render converts Markdown source to sanitized HTML
+func Render(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.ReplaceAll(source, "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "\uFFFD")
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+
+	var b strings.Builder
+	for _, block := range parseBlocks(lines, 0) {
+		writeNode(&b, block)
+	}
+	return b.String()
+}
+
+// maxNesting is how deep block quotes and lists may nest. Each level parses
+// its lines again, so without a bound a body of nothing but markers takes
+// time quadratic in its length.
+const maxNesting = 16
+
+// parseBlocks splits lines into block-level nodes, nested depth quotes and
+// list items deep. Past maxNesting, quote and list markers are kept as text.
+func parseBlocks(lines []string, depth int) []*node {
+	var blocks []*node
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		var block *node
+
+		switch {
+		case isBlank(line):
+			i++
+			continue
+		case isFence(line):
+			block, i = parseFencedCode(lines, i)
+		case indentOf(line) >= 4:
+			block, i = parseIndentedCode(lines, i)
+		case isThematicBreak(line):
+			block, i = element("hr"), i+1
+		case depth < maxNesting && isQuote(line):
+			block, i = parseQuote(lines, i, depth)
+		case depth < maxNesting && isListItem(line):
+			block, i = parseList(lines, i, depth)
+		default:
+			block, i = parseParagraph(lines, i)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// startsBlock reports whether line begins a block that ends a paragraph
+// before it
+func startsBlock(line string) bool {
+	if isFence(line) || isThematicBreak(line) || isQuote(line) {
+		return true
+	}
+
+	// Only lists that can't be mistaken for text interrupt a paragraph
+	marker, ok := parseListMarker(line)
+	return ok && !isBlank(marker.content(line)) && (!marker.ordered || marker.start == 1)
+}
+
+func parseParagraph(lines []string, i int) (*node, int) {
+	var text []string
+	for i < len(lines) && !isBlank(lines[i]) && (len(text) == 0 || !startsBlock(lines[i])) {
+		text = append(text, strings.TrimLeft(lines[i], " "))
+		i++
+	}
+
+	// Trailing spaces only matter as a line break before another line
+	last := len(text) - 1
+	text[last] = strings.TrimRight(text[last], " ")
+
+	return element("p", parseInline(strings.Join(text, "\n"))...), i
+}
+
+// isFence reports whether line opens a fenced code block
+func isFence(line string) bool {
+	_, _, _, ok := parseFence(line)
+	return ok
+}
+
+// parseFence reads a code fence line: its run of backticks or tildes, how far
+// it is indented and the info string after it
+func parseFence(line string) (marker string, indent int, info string, ok bool) {
+	indent = indentOf(line)
+	if indent > 3 {
+		return "", 0, "", false
+	}
+
+	rest := line[indent:]
+	if rest == "" || (rest[0] != '`' && rest[0] != '~') {
+		return "", 0, "", false
+	}
+	n := 0
+	for n < len(rest) && rest[n] == rest[0] {
+		n++
+	}
+	if n < 3 {
+		return "", 0, "", false
+	}
+
+	info = strings.TrimSpace(rest[n:])
+	if rest[0] == '`' && strings.Contains(info, "`") {
+		return "", 0, "", false
+	}
+	return rest[:n], indent, info, true
+}
+
+func parseFencedCode(lines []string, i int) (*node, int) {
+	marker, indent, info, _ := parseFence(lines[i])
+	i++
+
+	var code strings.Builder
+	for ; i < len(lines); i++ {
+		line := lines[i]
+
+		// The fence closes with at least as many of the same characters
+		if indentOf(line) <= 3 {
+			closing := strings.TrimSpace(line)
+			if len(closing) >= len(marker) && strings.Trim(closing, marker[:1]) == "" {
+				i++
+				break
+			}
+		}
+
+		code.WriteString(line[min(indent, indentOf(line)):])
+		code.WriteByte('\n')
+	}
+
+	block := element("code", textNode(code.String()))
+	if fields := strings.Fields(info); len(fields) > 0 {
+		block.attrs = append(block.attrs, attr{"class", "language-" + unescape(fields[0])})
+	}
+	return element("pre", block), i
+}
+
+func parseIndentedCode(lines []string, i int) (*node, int) {
+	var code []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if !isBlank(line) && indentOf(line) < 4 {
+			break
+		}
+		code = append(code, line[min(4, indentOf(line)):])
+	}
+
+	// Blank lines after the code belong to whatever follows
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+
+	return element("pre", element("code", textNode(strings.Join(code, "\n")+"\n"))), i
+}
+
+// isThematicBreak reports whether line is three or more *, - or _ alone
+func isThematicBreak(line string) bool {
+	if indentOf(line) > 3 {
+		return false
+	}
+
+	rest := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(rest) < 3 || !strings.ContainsRune("*-_", rune(rest[0])) {
+		return false
+	}
+	return strings.Trim(rest, rest[:1]) == ""
+}
+
+func isQuote(line string) bool {
+	indent := indentOf(line)
+	return indent <= 3 && indent < len(line) && line[indent] == '>'
+}
+
+// parseQuote collects the lines of a block quote without their > markers and
+// parses them as blocks of their own
+func parseQuote(lines []string, i, depth int) (*node, int) {
+	var inner []string
+	for i < len(lines) {
+		line := lines[i]
+		if isQuote(line) {
+			line = line[indentOf(line)+1:]
+			line = strings.TrimPrefix(line, " ")
+			inner = append(inner, line)
+			i++
+			continue
+		}
+
+		// A paragraph in the quote may carry on without the marker
+		if !isBlank(line) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(line) {
+			inner = append(inner, line)
+			i++
+			continue
+		}
+		break
+	}
+
+	return element("blockquote", parseBlocks(inner, depth+1)...), i
+}
+
+// listMarker is the bullet or number starting a list item
+type listMarker struct {
+	ordered bool
+
+	// The bullet character, or the . or ) after the number
+	char byte
+
+	start int
+
+	// Length of the marker with its indent and the spaces after it; the
+	// item's continuation lines are indented this far
+	width int
+}
+
+func isListItem(line string) bool {
+	_, ok := parseListMarker(line)
+	return ok
+}
+
+func parseListMarker(line string) (listMarker, bool) {
+	var marker listMarker
+
+	indent := indentOf(line)
+	if indent > 3 || isThematicBreak(line) {
+		return marker, false
+	}
+
+	rest := line[indent:]
+	end := 0
+	switch {
+	case rest != "" && strings.ContainsRune("-*+", rune(rest[0])):
+		marker.char = rest[0]
+		end = 1
+	default:
+		for end < len(rest) && end < 9 && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		if end == 0 || end == len(rest) || (rest[end] != '.' && rest[end] != ')') {
+			return marker, false
+		}
+		marker.ordered = true
+		marker.char = rest[end]
+		marker.start, _ = strconv.Atoi(rest[:end])
+		end++
+	}
+
+	// The marker needs a space after it, unless the item starts out empty
+	after := rest[end:]
+	if after != "" && after[0] != ' ' {
+		return marker, false
+	}
+	spaces := indentOf(after)
+	if spaces == 0 || spaces > 4 || spaces == len(after) {
+		// Content indented further is code inside the item
+		spaces = 1
+	}
+	marker.width = indent + end + spaces
+	return marker, true
+}
+
+// content is what follows the marker on the item's first line
+func (m listMarker) content(line string) string {
+	if m.width >= len(line) {
+		return ""
+	}
+	return line[m.width:]
+}
+
+// parseList collects consecutive items of the same kind of list. A list is
+// loose, with its items' text in paragraphs, when blank lines separate its
+// items or the blocks inside one.
+func parseList(lines []string, i, depth int) (*node, int) {
+	first, _ := parseListMarker(lines[i])
+
+	list := element("ul")
+	if first.ordered {
+		list.tag = "ol"
+		if first.start != 1 {
+			list.attrs = append(list.attrs, attr{"start", strconv.Itoa(first.start)})
+		}
+	}
+
+	loose := false
+	var items [][]*node
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i])
+		if !ok || marker.ordered != first.ordered || marker.char != first.char {
+			break
+		}
+
+		item := []string{marker.content(lines[i])}
+		i++
+
+	item:
+		for i < len(lines) {
+			line := lines[i]
+			switch {
+			case isBlank(line):
+				item = append(item, "")
+			case indentOf(line) >= marker.width:
+				item = append(item, line[marker.width:])
+			case !isBlank(item[len(item)-1]) && !startsBlock(line) && !isListItem(line):
+				// Lazy continuation of the item's paragraph
+				item = append(item, strings.TrimLeft(line, " "))
+			default:
+				break item
+			}
+			i++
+		}
+
+		trailing := 0
+		for len(item) > 1 && isBlank(item[len(item)-1]) {
+			item = item[:len(item)-1]
+			trailing++
+		}
+		if trailing > 0 && i < len(lines) && isListItem(lines[i]) {
+			loose = true
+		}
+		for _, line := range item[1:] {
+			if isBlank(line) {
+				loose = true
+			}
+		}
+
+		items = append(items, parseBlocks(item, depth+1))
+	}
+
+	for _, children := range items {
+		if !loose {
+			children = unwrapParagraphs(children)
+		}
+		list.children = append(list.children, element("li", children...))
+	}
+	return list, i
+}
+
+// unwrapParagraphs replaces paragraphs with their text, as in tight list items
+func unwrapParagraphs(blocks []*node) []*node {
+	var nodes []*node
+	for _, block := range blocks {
+		if block.tag == "p" {
+			nodes = append(nodes, block.children...)
+		} else {
+			nodes = append(nodes, block)
+		}
+	}
+	return nodes
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentOf counts the spaces a line starts with
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// expandTabs turns tabs in a line's indentation into spaces, to the next
+// multiple of four columns
+func expandTabs(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			b.WriteByte(' ')
+		case '\t':
+			b.WriteString(strings.Repeat(" ", 4-b.Len()%4))
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"emphasis", "*a* **b** snake_case", "<p><em>a</em> <strong>b</strong> snake_case</p>\n"},
+		{"code span", "`<b>`", "<p><code>&lt;b&gt;</code></p>\n"},
+		{"fenced code", "```go\nx := 1 < 2\n```", "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n"},
+		{"link", `[a](https://example.com "t")`, "<p><a href=\"https://example.com\" rel=\"nofollow\" title=\"t\">a</a></p>\n"},
+		{"autolink", "<me@example.com>", "<p><a href=\"mailto:me@example.com\" rel=\"nofollow\">me@example.com</a></p>\n"},
+		{"tight list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"ordered list", "3. a\n\n4. b", "<ol start=\"3\">\n<li><p>a</p>\n</li>\n<li><p>b</p>\n</li>\n</ol>\n"},
+		{"quote", "> a\nb", "<blockquote>\n<p>a\nb</p>\n</blockquote>\n"},
+		{"hard break", "a  \nb", "<p>a<br />b</p>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.source); got != tt.want {
+			t.Errorf("%s: Render(%q) = %q, want %q", tt.name, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name, source, want string
+	}{
+		{"raw html", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"javascript link", "[x](JavaScript:alert(1))", "<p>x</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>javascript:alert(1)</p>\n"},
+		{"data link", "[x](data:text/html,hi)", "<p>x</p>\n"},
+		{"quote in href", `[x](/a"onclick="b)`, "<p><a href=\"/a&#34;onclick=&#34;b\" rel=\"nofollow\">x</a></p>\n"},
+		{"code language", "``` \"><script>\n```", "<pre><code></code></pre>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.source); got != tt.want {
+			t.Errorf("%s: Render(%q) = %q, want %q", tt.name, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestRenderNestingLimit(t *testing.T) {
+	// Markers past the deepest level are kept as text
+	got := Render(strings.Repeat("> ", maxNesting+1) + "a")
+	want := strings.Repeat("<blockquote>\n", maxNesting) + "<p>&gt; a</p>\n" + strings.Repeat("</blockquote>\n", maxNesting)
+	if got != want {
+		t.Errorf("Render of %d nested quotes = %q, want %q", maxNesting+1, got, want)
+	}
+
+	tests := []struct {
+		name, source string
+	}{
+		{"quotes", strings.Repeat("> ", 10000)},
+		{"lists", strings.Repeat("- ", 10000) + "a"},
+		{"quoted lists", strings.Repeat("- > ", 5000)},
+		{"many lines", strings.Repeat(strings.Repeat("> - ", 2500)+"\n", 2)},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		got := Render(tt.source)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: Render took %v", tt.name, elapsed)
+		}
+		if depth := nestingDepth(got); depth > maxNesting {
+			t.Errorf("%s: Render nested %d blocks deep, want at most %d", tt.name, depth, maxNesting)
+		}
+	}
+}
+
+var containerTag = regexp.MustCompile(`</?(blockquote|ul|ol)\b`)
+
+// nestingDepth returns how deep block quotes and lists nest in rendered HTML
+func nestingDepth(html string) int {
+	depth, deepest := 0, 0
+	for _, tag := range containerTag.FindAllString(html, -1) {
+		if tag[1] == '/' {
+			depth--
+			continue
+		}
+		depth++
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
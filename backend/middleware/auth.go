@@ -4,24 +4,30 @@ package middleware
 import (
     "context"
     "database/sql"
+    "errors"
+    "log"
     "net/http"
+    "time"
     "forum/backend/models"
 )
 
+// SessionStatusHeader tells the client why a request was rejected with 401
+const SessionStatusHeader = "X-Session-Status"
+
 // AuthMiddleware checks for valid session and adds user ID to request context
 func AuthMiddleware(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        // Get session cookie
-        cookie, err := r.Cookie("session_id")
-        if err != nil {
-            http.Error(w, "Unauthorized", http.StatusUnauthorized)
-            return
-        }
-        
-        // Get session
-        session, err := models.GetSessionByID(db, cookie.Value)
-        if err != nil {
-            http.Error(w, "Invalid session", http.StatusUnauthorized)
+        session, status := authenticate(db, w, r)
+        if status != "" {
+            w.Header().Set(SessionStatusHeader, status)
+            switch status {
+            case "missing":
+                http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            case "expired":
+                http.Error(w, "Session expired", http.StatusUnauthorized)
+            default:
+                http.Error(w, "Invalid session", http.StatusUnauthorized)
+            }
             return
         }
         
@@ -34,8 +40,74 @@ func AuthMiddleware(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
     }
 }
 
+// OptionalAuthMiddleware adds the user ID to the request context when the
+// request carries a valid session, and lets anonymous requests through
+func OptionalAuthMiddleware(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if session, status := authenticate(db, w, r); status == "" {
+            ctx := context.WithValue(r.Context(), "userID", session.UserID)
+            r = r.WithContext(ctx)
+        }
+        next(w, r)
+    }
+}
+
+// authenticate loads the session named by the request's cookie and renews it.
+// It returns an empty status on success, otherwise "missing", "expired" or
+// "invalid". An expired session's cookie is cleared.
+func authenticate(db *sql.DB, w http.ResponseWriter, r *http.Request) (models.Session, string) {
+    // Get session cookie
+    cookie, err := r.Cookie("session_id")
+    if err != nil {
+        return models.Session{}, "missing"
+    }
+    
+    // Get session
+    session, err := models.GetSessionByID(db, cookie.Value)
+    if errors.Is(err, models.ErrSessionExpired) {
+        ClearSessionCookie(w)
+        return session, "expired"
+    }
+    if err != nil {
+        return session, "invalid"
+    }
+    
+    // Slide the expiry forward and refresh the cookie to match
+    session, renewed, err := models.RenewSession(db, session)
+    if err != nil {
+        log.Printf("Error renewing session: %v", err)
+    } else if renewed {
+        SetSessionCookie(w, session)
+    }
+    
+    return session, ""
+}
+
 // GetUserID retrieves user ID from request context
 func GetUserID(r *http.Request) (int, bool) {
     userID, ok := r.Context().Value("userID").(int)
     return userID, ok
-}
\ No newline at end of file
+}
+
+// SetSessionCookie writes the session cookie so it expires with the session
+func SetSessionCookie(w http.ResponseWriter, session models.Session) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     "session_id",
+        Value:    session.ID,
+        Path:     "/",
+        HttpOnly: true,
+        MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+        SameSite: http.SameSiteLaxMode,
+    })
+}
+
+// ClearSessionCookie tells the browser to drop the session cookie
+func ClearSessionCookie(w http.ResponseWriter) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     "session_id",
+        Value:    "",
+        Path:     "/",
+        HttpOnly: true,
+        MaxAge:   -1,
+    })
+}
@@ -0,0 +1,150 @@
+// backend/models/attachment.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// MaxAttachments is how many files a post or comment can have
+const MaxAttachments = 10
+
+// Attachment is an uploaded file. It belongs to whoever uploaded it until
+// they attach it to one of their posts or comments. Width and Height are
+// only known for images.
+type Attachment struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"-"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+var (
+	// ErrAttachmentNotFound is returned when attaching an upload that doesn't
+	// exist
+	ErrAttachmentNotFound = errors.New("attachment not found")
+
+	// ErrAttachmentNotOwned is returned when attaching someone else's upload
+	ErrAttachmentNotOwned = errors.New("attachment belongs to another user")
+
+	// ErrAttachmentInUse is returned when attaching an upload that is already
+	// attached elsewhere
+	ErrAttachmentInUse = errors.New("attachment already attached")
+)
+
+// CreateAttachment records a file a user uploaded, not yet attached to
+// anything
+func CreateAttachment(db *sql.DB, attachment Attachment) (int64, error) {
+	query := `
+	INSERT INTO attachments (user_id, filename, url, content_type, size, width, height)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := db.Exec(query, attachment.UserID, attachment.Filename, attachment.URL, attachment.Type,
+		attachment.Size, attachment.Width, attachment.Height)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// attachFiles attaches a user's uploads, given by ID, to a post or comment;
+// column is post_id or comment_id. Each upload must be the user's own and not
+// attached to anything yet.
+func attachFiles(tx *sql.Tx, column string, targetID, userID int, attachments []Attachment) error {
+	for _, attachment := range attachments {
+		id := attachment.ID
+		var owner int
+		var attached bool
+		err := tx.QueryRow(`SELECT user_id, post_id IS NOT NULL OR comment_id IS NOT NULL FROM attachments WHERE id = ?`,
+			id).Scan(&owner, &attached)
+		if err == sql.ErrNoRows {
+			return ErrAttachmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if owner != userID {
+			return ErrAttachmentNotOwned
+		}
+		if attached {
+			return ErrAttachmentInUse
+		}
+
+		if _, err := tx.Exec(`UPDATE attachments SET `+column+` = ? WHERE id = ?`, targetID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAttachments retrieves the attachments of posts or comments, in upload
+// order, keyed by their ID; column is post_id or comment_id
+func loadAttachments(db *sql.DB, column string, ids []int) (map[int][]Attachment, error) {
+	attachments := make(map[int][]Attachment, len(ids))
+	if len(ids) == 0 {
+		return attachments, nil
+	}
+
+	in, args := inClause(ids)
+	query := `
+	SELECT ` + column + `, id, user_id, filename, url, content_type, size, width, height
+	FROM attachments
+	WHERE ` + column + ` IN ` + in + `
+	ORDER BY id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		var a Attachment
+		if err := rows.Scan(&targetID, &a.ID, &a.UserID, &a.Filename, &a.URL, &a.Type, &a.Size, &a.Width, &a.Height); err != nil {
+			return nil, err
+		}
+		attachments[targetID] = append(attachments[targetID], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// loadPostAttachments fills in the attachments of each post
+func loadPostAttachments(db *sql.DB, posts []Post) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	attachments, err := loadAttachments(db, "post_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Attachments = attachments[posts[i].ID]
+	}
+	return nil
+}
+
+// loadCommentAttachments fills in the attachments of each comment
+func loadCommentAttachments(db *sql.DB, comments []Comment) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	attachments, err := loadAttachments(db, "comment_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Attachments = attachments[comments[i].ID]
+	}
+	return nil
+}
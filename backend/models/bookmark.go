@@ -0,0 +1,339 @@
+// backend/models/bookmark.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxBookmarkFolderLength is how long a bookmark's folder name can be
+const MaxBookmarkFolderLength = 50
+
+// Bookmark is a post or comment a user saved to read later, optionally filed
+// in one of their folders. Post is the bookmarked post, or the post the
+// bookmarked Comment is on.
+type Bookmark struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"`
+	Folder    string    `json:"folder"`
+	CreatedAt time.Time `json:"createdAt"`
+	Post      Post      `json:"post"`
+	Comment   *Comment  `json:"comment,omitempty"`
+}
+
+// BookmarkFolder is one of a user's bookmark folders with how many bookmarks
+// it holds; bookmarks without a folder are counted under the empty name
+type BookmarkFolder struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// BookmarkPage is one page of a user's bookmarks, newest first. NextCursor is
+// nil on the last page.
+type BookmarkPage struct {
+	Items      []Bookmark `json:"items"`
+	NextCursor *int       `json:"nextCursor"`
+}
+
+var (
+	// ErrBookmarkTargetNotFound is returned when bookmarking a post or comment
+	// that doesn't exist, has been deleted or isn't published yet
+	ErrBookmarkTargetNotFound = errors.New("bookmark target not found")
+
+	// ErrInvalidBookmarkFolder is returned for a folder name that is too long
+	ErrInvalidBookmarkFolder = errors.New("invalid bookmark folder")
+)
+
+// NormalizeBookmarkFolder trims a folder name and checks its length
+func NormalizeBookmarkFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if len([]rune(folder)) > MaxBookmarkFolderLength {
+		return "", ErrInvalidBookmarkFolder
+	}
+	return folder, nil
+}
+
+// AddBookmark saves a post or comment for a user in a folder, which may be
+// empty. Bookmarking it again moves it to the new folder.
+func AddBookmark(db *sql.DB, userID int, targetType string, targetID int, folder string) error {
+	var exists bool
+	var err error
+	switch targetType {
+	case TargetPost:
+		err = db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL AND status = 'published')`,
+			targetID).Scan(&exists)
+	case TargetComment:
+		err = db.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM comments c
+			JOIN posts p ON c.post_id = p.id
+			WHERE c.id = ? AND p.deleted_at IS NULL AND p.status = 'published')`, targetID).Scan(&exists)
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBookmarkTargetNotFound
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO bookmarks (user_id, target_type, target_id, folder) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, target_type, target_id) DO UPDATE SET folder = excluded.folder`,
+		userID, targetType, targetID, folder)
+	return err
+}
+
+// RemoveBookmark takes a post or comment out of a user's bookmarks. Removing
+// one that isn't bookmarked does nothing.
+func RemoveBookmark(db *sql.DB, userID int, targetType string, targetID int) error {
+	_, err := db.Exec(`DELETE FROM bookmarks WHERE user_id = ? AND target_type = ? AND target_id = ?`,
+		userID, targetType, targetID)
+	return err
+}
+
+// GetBookmarks retrieves a page of a user's bookmarks, newest first, after
+// the bookmark with ID before when it is set. An empty folder means every
+// folder. Bookmarks of deleted posts, and of comments on them, are left out.
+func GetBookmarks(db *sql.DB, userID int, folder string, before, limit int) (BookmarkPage, error) {
+	page := BookmarkPage{Items: []Bookmark{}}
+
+	query := `
+	SELECT b.id, b.target_type, b.target_id, b.folder, b.created_at, p.id
+	FROM bookmarks b
+	LEFT JOIN comments c ON b.target_type = 'comment' AND c.id = b.target_id
+	JOIN posts p ON p.id = CASE WHEN b.target_type = 'post' THEN b.target_id ELSE c.post_id END
+	WHERE b.user_id = ? AND p.deleted_at IS NULL AND p.status = 'published'`
+	args := []interface{}{userID}
+	if folder != "" {
+		query += "\n\tAND b.folder = ?"
+		args = append(args, folder)
+	}
+	if before > 0 {
+		query += "\n\tAND b.id < ?"
+		args = append(args, before)
+	}
+	query += "\n\tORDER BY b.id DESC\n\tLIMIT ?"
+
+	// Fetch one extra bookmark to learn whether another page follows
+	args = append(args, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var postIDs, commentIDs []int
+	targets := make(map[int]int)
+	for rows.Next() {
+		if len(page.Items) == limit {
+			cursor := page.Items[len(page.Items)-1].ID
+			page.NextCursor = &cursor
+			break
+		}
+
+		var bookmark Bookmark
+		var targetID, postID int
+		if err := rows.Scan(&bookmark.ID, &bookmark.Type, &targetID, &bookmark.Folder, &bookmark.CreatedAt, &postID); err != nil {
+			return page, err
+		}
+		bookmark.Post.ID = postID
+		targets[bookmark.ID] = targetID
+		postIDs = append(postIDs, postID)
+		if bookmark.Type == TargetComment {
+			commentIDs = append(commentIDs, targetID)
+		}
+		page.Items = append(page.Items, bookmark)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	rows.Close()
+
+	posts, err := getPostsByIDs(db, postIDs, userID)
+	if err != nil {
+		return page, err
+	}
+	comments, err := getCommentsByIDs(db, commentIDs, userID)
+	if err != nil {
+		return page, err
+	}
+	for i := range page.Items {
+		bookmark := &page.Items[i]
+		bookmark.Post = posts[bookmark.Post.ID]
+		if bookmark.Type == TargetComment {
+			comment := comments[targets[bookmark.ID]]
+			bookmark.Comment = &comment
+		}
+	}
+
+	return page, nil
+}
+
+// GetBookmarkFolders lists the folders a user has filed bookmarks in, by name
+func GetBookmarkFolders(db *sql.DB, userID int) ([]BookmarkFolder, error) {
+	rows, err := db.Query(`
+		SELECT folder, COUNT(*) FROM bookmarks
+		WHERE user_id = ?
+		GROUP BY folder
+		ORDER BY folder`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []BookmarkFolder{}
+	for rows.Next() {
+		var folder BookmarkFolder
+		if err := rows.Scan(&folder.Name, &folder.Count); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
+
+// getPostsByIDs retrieves published posts with all their details as seen by
+// viewerID, keyed by ID
+func getPostsByIDs(db *sql.DB, ids []int, viewerID int) (map[int]Post, error) {
+	found := make(map[int]Post, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT`+postColumns+`
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id IN `+in+` AND p.deleted_at IS NULL AND p.status = 'published'`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadPostDetails(db, posts, viewerID); err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		found[post.ID] = post
+	}
+	return found, nil
+}
+
+// getCommentsByIDs retrieves comments with their attachments, and reactions
+// and bookmarks as seen by viewerID, keyed by ID
+func getCommentsByIDs(db *sql.DB, ids []int, viewerID int) (map[int]Comment, error) {
+	found := make(map[int]Comment, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT`+commentColumns+`
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.id IN `+in, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadCommentDetails(db, comments, viewerID); err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		found[comment.ID] = comment
+	}
+	return found, nil
+}
+
+// loadBookmarked reports which of the given posts or comments viewerID has
+// bookmarked
+func loadBookmarked(db *sql.DB, targetType string, ids []int, viewerID int) (map[int]bool, error) {
+	bookmarked := make(map[int]bool)
+	if len(ids) == 0 || viewerID == 0 {
+		return bookmarked, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT target_id FROM bookmarks
+		WHERE user_id = ? AND target_type = ? AND target_id IN `+in,
+		append([]interface{}{viewerID, targetType}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		bookmarked[id] = true
+	}
+	return bookmarked, rows.Err()
+}
+
+// loadPostBookmarks marks the posts viewerID has bookmarked
+func loadPostBookmarks(db *sql.DB, posts []Post, viewerID int) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	bookmarked, err := loadBookmarked(db, TargetPost, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].IsBookmarked = bookmarked[posts[i].ID]
+	}
+	return nil
+}
+
+// loadCommentBookmarks marks the comments viewerID has bookmarked
+func loadCommentBookmarks(db *sql.DB, comments []Comment, viewerID int) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	bookmarked, err := loadBookmarked(db, TargetComment, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].IsBookmarked = bookmarked[comments[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// backend/models/category.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+type Category struct {
+	ID          int    `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SortOrder   int    `json:"sortOrder"`
+}
+
+var (
+	// ErrCategoryNotFound is returned when a category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+
+	// ErrCategoryExists is returned when another category already has the slug
+	ErrCategoryExists = errors.New("category already exists")
+)
+
+// Slugify turns a category name into its URL-safe slug
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// CategoryNames joins the names of categories, as stored in posts.category
+func CategoryNames(categories []Category) string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// GetAllCategories retrieves every category in display order
+func GetAllCategories(db *sql.DB) ([]Category, error) {
+	query := `SELECT id, slug, name, description, sort_order FROM categories ORDER BY sort_order, name`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Slug, &category.Name, &category.Description, &category.SortOrder); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// GetCategoryByID retrieves a single category
+func GetCategoryByID(db *sql.DB, id int) (Category, error) {
+	var category Category
+	query := `SELECT id, slug, name, description, sort_order FROM categories WHERE id = ?`
+
+	err := db.QueryRow(query, id).Scan(&category.ID, &category.Slug, &category.Name, &category.Description, &category.SortOrder)
+	if err == sql.ErrNoRows {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// GetCategoriesByIDs retrieves the given categories in display order,
+// ignoring duplicates. It returns ErrCategoryNotFound if any ID is unknown.
+func GetCategoriesByIDs(db *sql.DB, ids []int) ([]Category, error) {
+	all, err := GetAllCategories(db)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var categories []Category
+	for _, category := range all {
+		if wanted[category.ID] {
+			categories = append(categories, category)
+		}
+	}
+	if len(categories) != len(wanted) {
+		return nil, ErrCategoryNotFound
+	}
+
+	return categories, nil
+}
+
+// CreateCategory adds a category, deriving its slug from the name when empty
+func CreateCategory(db *sql.DB, category Category) (int64, error) {
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	if err := checkSlugFree(db, category.Slug, 0); err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO categories (slug, name, description, sort_order) VALUES (?, ?, ?, ?)`
+	result, err := db.Exec(query, category.Slug, category.Name, category.Description, category.SortOrder)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// UpdateCategory changes a category's slug, name, description and sort order
+func UpdateCategory(db *sql.DB, category Category) error {
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	if err := checkSlugFree(db, category.Slug, category.ID); err != nil {
+		return err
+	}
+
+	query := `UPDATE categories SET slug = ?, name = ?, description = ?, sort_order = ? WHERE id = ?`
+	result, err := db.Exec(query, category.Slug, category.Name, category.Description, category.SortOrder, category.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// DeleteCategory removes a category, taking it off every post and dropping
+// the posts pinned in it
+func DeleteCategory(db *sql.DB, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM post_categories WHERE category_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM post_pins WHERE category_id = ?`, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return tx.Commit()
+}
+
+// checkSlugFree returns ErrCategoryExists if a category other than exceptID uses slug
+func checkSlugFree(db *sql.DB, slug string, exceptID int) error {
+	var id int
+	err := db.QueryRow(`SELECT id FROM categories WHERE slug = ? AND id != ?`, slug, exceptID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrCategoryExists
+}
+
+// setPostCategories replaces the categories a post belongs to
+func setPostCategories(tx *sql.Tx, postID int, categories []Category) error {
+	if _, err := tx.Exec(`DELETE FROM post_categories WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, category := range categories {
+		_, err := tx.Exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, postID, category.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPostCategories fills in the categories of each post, and its category
+// names from them
+func loadPostCategories(db *sql.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		index[post.ID] = i
+		ids[i] = post.ID
+		posts[i].Categories = []Category{}
+	}
+
+	in, args := inClause(ids)
+	query := `
+	SELECT pc.post_id, c.id, c.slug, c.name, c.description, c.sort_order
+	FROM post_categories pc
+	JOIN categories c ON pc.category_id = c.id
+	WHERE pc.post_id IN ` + in + `
+	ORDER BY c.sort_order, c.name`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var category Category
+		if err := rows.Scan(&postID, &category.ID, &category.Slug, &category.Name, &category.Description, &category.SortOrder); err != nil {
+			return err
+		}
+		i := index[postID]
+		posts[i].Categories = append(posts[i].Categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Category = CategoryNames(posts[i].Categories)
+	}
+	return nil
+}
@@ -3,41 +3,336 @@ package models
 
 import (
     "database/sql"
+    "errors"
     "time"
+    
+    "forum/backend/markdown"
 )
 
 type Comment struct {
-    ID        int       `json:"id"`
-    PostID    int       `json:"postId"`
-    UserID    int       `json:"userId"`
-    Content   string    `json:"content"`
-    CreatedAt time.Time `json:"createdAt"`
-    User      User      `json:"user"`
+    ID           int          `json:"id"`
+    PostID       int          `json:"postId"`
+    UserID       int          `json:"userId"`
+    ParentID     *int         `json:"parentId"`
+    Content      string       `json:"content"`
+    ContentHTML  string       `json:"contentHtml"`
+    CreatedAt    time.Time    `json:"createdAt"`
+    User         User         `json:"user"`
+    Attachments  []Attachment `json:"attachments,omitempty"`
+    IsBookmarked bool         `json:"isBookmarked"`
+    Reactions
+    
+    // How deeply the comment is nested; top-level comments are at depth 0
+    Depth int `json:"depth"`
+    
+    // Number of direct replies, of which Replies holds the first page.
+    // RepliesCursor is set when more replies can be loaded with it.
+    ReplyCount    int       `json:"replyCount"`
+    Replies       []Comment `json:"replies,omitempty"`
+    RepliesCursor *int      `json:"repliesCursor,omitempty"`
+}
+
+// CommentTreeOptions limits how much of a comment thread is returned at once
+type CommentTreeOptions struct {
+    // Levels of replies below the requested comments. Deeper replies are
+    // left out; their parent's RepliesCursor loads them.
+    MaxDepth int
+    
+    // Replies returned under any one comment
+    PageSize int
+    
+    // Comments returned in all, counting replies at every level. Replies
+    // past it are left out as if too deep.
+    MaxComments int
+}
+
+// DefaultCommentTreeOptions are used when the client doesn't ask otherwise
+var DefaultCommentTreeOptions = CommentTreeOptions{MaxDepth: 3, PageSize: 10, MaxComments: 200}
+
+// CommentPage is one page of comments with their replies. NextCursor is nil
+// on the last page.
+type CommentPage struct {
+    Items      []Comment `json:"items"`
+    NextCursor *int      `json:"nextCursor"`
+}
+
+// ErrCommentNotFound is returned when a comment does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// commentColumns selects a comment with its author
+const commentColumns = `
+    c.id, c.post_id, c.user_id, c.parent_id, c.content, c.content_html, c.created_at,
+    u.id, u.nickname, u.email`
+
+// scanComment reads a row selected with commentColumns
+func scanComment(row interface{ Scan(...interface{}) error }) (Comment, error) {
+    var comment Comment
+    var user User
+    var parentID sql.NullInt64
+    
+    err := row.Scan(
+        &comment.ID, &comment.PostID, &comment.UserID, &parentID, &comment.Content, &comment.ContentHTML, &comment.CreatedAt,
+        &user.ID, &user.Nickname, &user.Email,
+    )
+    if err != nil {
+        return comment, err
+    }
+    
+    if parentID.Valid {
+        id := int(parentID.Int64)
+        comment.ParentID = &id
+    }
+    comment.User = user
+    return comment, nil
 }
 
-// CreateComment adds a new comment to a post
+// CreateComment adds a new comment to a post, as a reply when ParentID is
+// set, rendering its Markdown content and attaching the commenter's uploads
+// listed in Attachments. It returns ErrPostLocked or ErrPostArchived if the
+// post is closed to comments.
 func CreateComment(db *sql.DB, comment Comment) (int64, error) {
-    query := `INSERT INTO comments (post_id, user_id, content) VALUES (?, ?, ?)`
+    if err := checkPostWritable(db, comment.PostID); err != nil {
+        return 0, err
+    }
+    
+    tx, err := db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+    
+    query := `INSERT INTO comments (post_id, user_id, parent_id, content, content_html) VALUES (?, ?, ?, ?, ?)`
+    
+    result, err := tx.Exec(query, comment.PostID, comment.UserID, comment.ParentID, comment.Content, markdown.Render(comment.Content))
+    if err != nil {
+        return 0, err
+    }
     
-    result, err := db.Exec(query, comment.PostID, comment.UserID, comment.Content)
+    commentID, err := result.LastInsertId()
     if err != nil {
         return 0, err
     }
     
-    return result.LastInsertId()
+    if err := attachFiles(tx, "comment_id", int(commentID), comment.UserID, comment.Attachments); err != nil {
+        return 0, err
+    }
+    
+    return commentID, tx.Commit()
 }
 
-// GetCommentsByPostID retrieves all comments for a specific post
-func GetCommentsByPostID(db *sql.DB, postID int) ([]Comment, error) {
+// GetCommentByID retrieves a single comment with its depth and attachments,
+// and reactions and bookmarks as seen by viewerID
+func GetCommentByID(db *sql.DB, commentID int, viewerID int) (Comment, error) {
     query := `
-    SELECT c.id, c.post_id, c.user_id, c.content, c.created_at,
-           u.id, u.nickname, u.email
+    SELECT` + commentColumns + `
     FROM comments c
     JOIN users u ON c.user_id = u.id
-    WHERE c.post_id = ?
-    ORDER BY c.created_at ASC`
+    WHERE c.id = ?`
+    
+    comment, err := scanComment(db.QueryRow(query, commentID))
+    if err == sql.ErrNoRows {
+        return comment, ErrCommentNotFound
+    }
+    if err != nil {
+        return comment, err
+    }
+    
+    comment.Depth, err = commentDepth(db, comment.PostID, comment.ID)
+    if err != nil {
+        return comment, err
+    }
+    
+    comments := []Comment{comment}
+    if err := loadCommentDetails(db, comments, viewerID); err != nil {
+        return comment, err
+    }
+    return comments[0], nil
+}
+
+// loadCommentDetails fills in the attachments of each comment, and its
+// reactions and whether it is bookmarked as seen by viewerID
+func loadCommentDetails(db *sql.DB, comments []Comment, viewerID int) error {
+    if err := loadCommentReactions(db, comments, viewerID); err != nil {
+        return err
+    }
+    if err := loadCommentAttachments(db, comments); err != nil {
+        return err
+    }
+    return loadCommentBookmarks(db, comments, viewerID)
+}
+
+// GetCommentTree retrieves a page of the replies to parentID, or of the
+// post's top-level comments when parentID is 0, after the comment afterID.
+// Each comment comes with its own replies down to opts.MaxDepth levels, oldest
+// first, until opts.MaxComments comments are returned; a comment whose
+// replies were left out has a RepliesCursor to load them. Only the comments
+// being returned are queried, one level of the thread at a time, so the cost
+// of a page is bounded however large the thread is.
+func GetCommentTree(db *sql.DB, postID, parentID, afterID, viewerID int, opts CommentTreeOptions) (CommentPage, error) {
+    page := CommentPage{Items: []Comment{}}
+    
+    depth := 0
+    if parentID != 0 {
+        parentDepth, err := commentDepth(db, postID, parentID)
+        if err != nil {
+            return page, err
+        }
+        depth = parentDepth + 1
+    }
     
-    rows, err := db.Query(query, postID)
+    // Fetch one extra comment to learn whether another page follows; for
+    // replies, their parent's reply count tells
+    top, err := queryComments(db, `
+    SELECT`+commentColumns+`
+    FROM comments c
+    JOIN users u ON c.user_id = u.id
+    WHERE c.post_id = ? AND COALESCE(c.parent_id, 0) = ? AND c.id > ?
+    ORDER BY c.id
+    LIMIT ?`, postID, parentID, afterID, opts.PageSize+1)
+    if err != nil {
+        return page, err
+    }
+    
+    // Index the thread by parent, fetching each level of replies under the
+    // comments shown from the level above
+    children := map[int][]Comment{parentID: top}
+    shown := top
+    if len(shown) > opts.PageSize {
+        shown = shown[:opts.PageSize]
+    }
+    ids := commentIDs(shown)
+    for levels := 0; levels < opts.MaxDepth && len(shown) > 0 && len(ids) < opts.MaxComments; levels++ {
+        replies, err := queryReplies(db, postID, commentIDs(shown), opts.PageSize)
+        if err != nil {
+            return page, err
+        }
+        
+        // Past the budget, replies are dropped and their parents' cursors
+        // load them instead
+        if room := opts.MaxComments - len(ids); len(replies) > room {
+            replies = replies[:room]
+        }
+        for _, reply := range replies {
+            children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+        }
+        shown = replies
+        ids = append(ids, commentIDs(shown)...)
+    }
+    
+    replyCounts, err := countReplies(db, ids)
+    if err != nil {
+        return page, err
+    }
+    page.Items, page.NextCursor = buildCommentTree(children, replyCounts, parentID, depth, opts.PageSize)
+    
+    // Load reactions, attachments and bookmarks for the comments being
+    // returned
+    reactions, err := loadReactions(db, TargetComment, ids, viewerID)
+    if err != nil {
+        return page, err
+    }
+    attachments, err := loadAttachments(db, "comment_id", ids)
+    if err != nil {
+        return page, err
+    }
+    bookmarked, err := loadBookmarked(db, TargetComment, ids, viewerID)
+    if err != nil {
+        return page, err
+    }
+    walkComments(page.Items, func(comment *Comment) {
+        comment.Reactions = reactions[comment.ID]
+        comment.Attachments = attachments[comment.ID]
+        comment.IsBookmarked = bookmarked[comment.ID]
+    })
+    
+    return page, nil
+}
+
+// walkComments calls fn for each comment of a tree, parents before replies
+func walkComments(comments []Comment, fn func(*Comment)) {
+    for i := range comments {
+        fn(&comments[i])
+        walkComments(comments[i].Replies, fn)
+    }
+}
+
+// buildCommentTree returns a page of the fetched replies to parentID, each
+// at depth and with its fetched replies filled in
+func buildCommentTree(children map[int][]Comment, replyCounts map[int]int, parentID, depth, pageSize int) ([]Comment, *int) {
+    replies := []Comment{}
+    var cursor *int
+    
+    for _, comment := range children[parentID] {
+        if len(replies) == pageSize {
+            last := replies[len(replies)-1].ID
+            cursor = &last
+            break
+        }
+        
+        comment.Depth = depth
+        comment.ReplyCount = replyCounts[comment.ID]
+        if len(children[comment.ID]) > 0 {
+            comment.Replies, _ = buildCommentTree(children, replyCounts, comment.ID, depth+1, pageSize)
+        }
+        if len(comment.Replies) < comment.ReplyCount {
+            // Replies too deep, past the page size or past the budget to
+            // include; load them after the last one shown
+            after := 0
+            if n := len(comment.Replies); n > 0 {
+                after = comment.Replies[n-1].ID
+            }
+            comment.RepliesCursor = &after
+        }
+        replies = append(replies, comment)
+    }
+    
+    return replies, cursor
+}
+
+// commentDepth finds how deeply a comment on a post is nested, by counting
+// its ancestors
+func commentDepth(db *sql.DB, postID, commentID int) (int, error) {
+    var chain int
+    err := db.QueryRow(`
+    WITH RECURSIVE ancestors (id, parent_id) AS (
+        SELECT id, parent_id FROM comments WHERE id = ? AND post_id = ?
+        UNION ALL
+        SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+    )
+    SELECT COUNT(*) FROM ancestors`, commentID, postID).Scan(&chain)
+    if err != nil {
+        return 0, err
+    }
+    if chain == 0 {
+        return 0, ErrCommentNotFound
+    }
+    return chain - 1, nil
+}
+
+// queryReplies retrieves the first perParent replies to each of parentIDs,
+// oldest first
+func queryReplies(db *sql.DB, postID int, parentIDs []int, perParent int) ([]Comment, error) {
+    in, args := inClause(parentIDs)
+    args = append(append([]interface{}{postID}, args...), perParent)
+    return queryComments(db, `
+    SELECT`+commentColumns+`
+    FROM comments c
+    JOIN users u ON c.user_id = u.id
+    WHERE c.id IN (
+        SELECT id FROM (
+            SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY id) AS n
+            FROM comments
+            WHERE post_id = ? AND parent_id IN `+in+`
+        )
+        WHERE n <= ?
+    )
+    ORDER BY c.id`, args...)
+}
+
+// queryComments retrieves the comments selected with commentColumns by
+// query, without reactions or attachments
+func queryComments(db *sql.DB, query string, args ...interface{}) ([]Comment, error) {
+    rows, err := db.Query(query, args...)
     if err != nil {
         return nil, err
     }
@@ -45,20 +340,46 @@ func GetCommentsByPostID(db *sql.DB, postID int) ([]Comment, error) {
     
     var comments []Comment
     for rows.Next() {
-        var comment Comment
-        var user User
-        
-        err := rows.Scan(
-            &comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt,
-            &user.ID, &user.Nickname, &user.Email,
-        )
+        comment, err := scanComment(rows)
         if err != nil {
             return nil, err
         }
         
-        comment.User = user
         comments = append(comments, comment)
     }
     
-    return comments, nil
-}
\ No newline at end of file
+    return comments, rows.Err()
+}
+
+// countReplies counts the direct replies to each of the given comments
+func countReplies(db *sql.DB, ids []int) (map[int]int, error) {
+    counts := make(map[int]int)
+    if len(ids) == 0 {
+        return counts, nil
+    }
+    
+    in, args := inClause(ids)
+    rows, err := db.Query(`SELECT parent_id, COUNT(*) FROM comments WHERE parent_id IN `+in+` GROUP BY parent_id`, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    for rows.Next() {
+        var id, count int
+        if err := rows.Scan(&id, &count); err != nil {
+            return nil, err
+        }
+        counts[id] = count
+    }
+    return counts, rows.Err()
+}
+
+// commentIDs lists the IDs of comments
+func commentIDs(comments []Comment) []int {
+    ids := make([]int, len(comments))
+    for i, comment := range comments {
+        ids[i] = comment.ID
+    }
+    return ids
+}
@@ -0,0 +1,199 @@
+// backend/models/draft.go
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"forum/backend/markdown"
+)
+
+// Post statuses. Drafts are only visible to their author until published,
+// by hand or by the scheduler once their publish time comes.
+const (
+	PostDraft     = "draft"
+	PostPublished = "published"
+)
+
+// SaveDraft creates a draft when draft.ID is 0, or replaces the content,
+// categories and tags of one of the author's drafts. Its publish time is
+// replaced by draft.PublishAt only when schedule is set, so saves that leave
+// the schedule alone keep it. A draft's created_at records when it was last
+// saved until it is published. It returns ErrPostNotFound if the draft
+// doesn't exist, belongs to someone else or has already been published.
+func SaveDraft(db *sql.DB, draft Post, schedule bool) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var publishAt interface{}
+	if draft.PublishAt != nil {
+		publishAt = draft.PublishAt.UTC().Format(feedTimeFormat)
+	}
+
+	if draft.ID == 0 {
+		result, err := tx.Exec(`
+			INSERT INTO posts (user_id, title, content, content_html, category, status, publish_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			draft.UserID, draft.Title, draft.Content, markdown.Render(draft.Content), CategoryNames(draft.Categories),
+			PostDraft, publishAt)
+		if err != nil {
+			return 0, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		draft.ID = int(id)
+	} else {
+		result, err := tx.Exec(`
+			UPDATE posts
+			SET title = ?, content = ?, content_html = ?, category = ?,
+				publish_at = CASE WHEN ? THEN ? ELSE publish_at END, created_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND user_id = ? AND status = ? AND deleted_at IS NULL`,
+			draft.Title, draft.Content, markdown.Render(draft.Content), CategoryNames(draft.Categories), schedule, publishAt,
+			draft.ID, draft.UserID, PostDraft)
+		if err != nil {
+			return 0, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, ErrPostNotFound
+		}
+	}
+
+	if err := setPostCategories(tx, draft.ID, draft.Categories); err != nil {
+		return 0, err
+	}
+	if err := setPostTags(tx, draft.ID, draft.Tags); err != nil {
+		return 0, err
+	}
+
+	return draft.ID, tx.Commit()
+}
+
+// GetDrafts retrieves a user's drafts, most recently saved first
+func GetDrafts(db *sql.DB, userID int) ([]Post, error) {
+	query := `
+	SELECT` + postColumns + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.user_id = ? AND p.status = ? AND p.deleted_at IS NULL
+	ORDER BY p.created_at DESC, p.id DESC`
+
+	rows, err := db.Query(query, userID, PostDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []Post{}
+	for rows.Next() {
+		draft, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadPostCategories(db, drafts); err != nil {
+		return nil, err
+	}
+	if err := loadPostTags(db, drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// PublishDraft publishes one of the author's drafts now. The post is dated
+// from when it was published, not when it was first drafted.
+func PublishDraft(db *sql.DB, postID, userID int) error {
+	result, err := db.Exec(`
+		UPDATE posts SET status = ?, publish_at = NULL, created_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND status = ? AND deleted_at IS NULL`,
+		PostPublished, postID, userID, PostDraft)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// PublishDueDrafts publishes every draft whose publish time has come and
+// returns their IDs
+func PublishDueDrafts(db *sql.DB, now time.Time) ([]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id FROM posts
+		WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL
+		ORDER BY publish_at, id`,
+		PostDraft, now.UTC().Format(feedTimeFormat))
+	if err != nil {
+		return nil, err
+	}
+	var postIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		postIDs = append(postIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, id := range postIDs {
+		_, err := tx.Exec(`UPDATE posts SET status = ?, publish_at = NULL, created_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			PostPublished, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return postIDs, tx.Commit()
+}
+
+// StartPostScheduler publishes due drafts every interval and calls onPublish
+// for each one so connected clients can be told about it. It blocks, so run
+// it in its own goroutine.
+func StartPostScheduler(db *sql.DB, interval time.Duration, onPublish func(postID int)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		postIDs, err := PublishDueDrafts(db, now)
+		if err != nil {
+			log.Printf("Error publishing scheduled posts: %v", err)
+			continue
+		}
+		if len(postIDs) > 0 {
+			log.Printf("Published %d scheduled posts", len(postIDs))
+		}
+		for _, postID := range postIDs {
+			onPublish(postID)
+		}
+	}
+}
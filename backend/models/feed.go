@@ -0,0 +1,267 @@
+// backend/models/feed.go
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Sort orders for the post feed
+const (
+	SortNewest         = "newest"
+	SortMostCommented  = "comments"
+	SortRecentActivity = "activity"
+	SortHot            = "hot"
+	SortTop            = "top"
+)
+
+// feedTimeFormat matches how SQLite's CURRENT_TIMESTAMP stores post and
+// comment times, so they compare correctly as text
+const feedTimeFormat = "2006-01-02 15:04:05"
+
+var (
+	// ErrInvalidCursor is returned for a cursor that wasn't issued for this sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrInvalidSort is returned for an unknown sort order
+	ErrInvalidSort = errors.New("invalid sort")
+
+	// ErrInvalidWindow is returned for an unknown time window
+	ErrInvalidWindow = errors.New("invalid window")
+)
+
+// sortKeys is the SQL expression each sort orders posts by, newest or
+// largest first, with the post ID breaking ties. Hot and top read the scores
+// last computed by RefreshRankings.
+var sortKeys = map[string]string{
+	SortNewest:         `(p.created_at || '')`,
+	SortMostCommented:  `(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id)`,
+	SortRecentActivity: `COALESCE((SELECT MAX(c.created_at) FROM comments c WHERE c.post_id = p.id), p.created_at)`,
+	SortHot:            `COALESCE((SELECT s.hot FROM post_scores s WHERE s.post_id = p.id), 0.0)`,
+	SortTop:            `COALESCE((SELECT s.score FROM post_scores s WHERE s.post_id = p.id), 0.0)`,
+}
+
+// PostFilter selects one page of the post feed. Zero values mean no filter.
+type PostFilter struct {
+	CategorySlug string
+	AuthorID     int
+
+	// Only posts with every one of these normalized tags
+	Tags []string
+
+	// Posts created at or after From and before To
+	From time.Time
+	To   time.Time
+
+	// Only posts created within the last day, week or month, e.g. for the
+	// top posts of the week
+	Window string
+
+	// Only posts this user has commented on
+	CommentedBy int
+
+	// Archived posts are left out unless asked for
+	IncludeArchived bool
+
+	// User whose own reactions are included
+	ViewerID int
+
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// PostPage is one page of the post feed. NextCursor is nil on the last page.
+// The first page of the front page or a category also lists the posts pinned
+// there, which are left out of Items.
+type PostPage struct {
+	Pinned     []Post  `json:"pinned,omitempty"`
+	Items      []Post  `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+// feedCursor is the position after the last post of a page. It is handed to
+// clients base64 encoded.
+type feedCursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   int         `json:"id"`
+}
+
+// ValidSort reports whether sort is one of the feed's sort orders
+func ValidSort(sort string) bool {
+	_, ok := sortKeys[sort]
+	return ok
+}
+
+// GetPosts retrieves a page of posts matching the filter
+func GetPosts(db *sql.DB, filter PostFilter) (PostPage, error) {
+	page := PostPage{Items: []Post{}}
+
+	if filter.Sort == "" {
+		filter.Sort = SortNewest
+	}
+	key, ok := sortKeys[filter.Sort]
+	if !ok {
+		return page, ErrInvalidSort
+	}
+
+	var conditions []string
+	var args []interface{}
+
+	if filter.CategorySlug != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM post_categories pc
+			JOIN categories cat ON pc.category_id = cat.id
+			WHERE pc.post_id = p.id AND cat.slug = ?)`)
+		args = append(args, filter.CategorySlug)
+	}
+	for _, tag := range filter.Tags {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM post_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE pt.post_id = p.id AND t.name = ?)`)
+		args = append(args, tag)
+	}
+	if filter.AuthorID > 0 {
+		conditions = append(conditions, `p.user_id = ?`)
+		args = append(args, filter.AuthorID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, `p.created_at >= ?`)
+		args = append(args, filter.From.UTC().Format(feedTimeFormat))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, `p.created_at < ?`)
+		args = append(args, filter.To.UTC().Format(feedTimeFormat))
+	}
+	if filter.Window != "" {
+		duration, ok := windowDurations[filter.Window]
+		if !ok {
+			return page, ErrInvalidWindow
+		}
+		conditions = append(conditions, `p.created_at >= ?`)
+		args = append(args, time.Now().Add(-duration).UTC().Format(feedTimeFormat))
+	}
+	if filter.CommentedBy > 0 {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id AND c.user_id = ?)`)
+		args = append(args, filter.CommentedBy)
+	}
+	if !filter.IncludeArchived {
+		conditions = append(conditions, `p.archived_at IS NULL`)
+	}
+
+	// Pins only apply to the plain front page and category feeds
+	pinned := len(filter.Tags) == 0 && filter.AuthorID == 0 && filter.From.IsZero() && filter.To.IsZero() &&
+		filter.Window == "" && filter.CommentedBy == 0
+	if pinned {
+		conditions = append(conditions, `NOT EXISTS (
+			SELECT 1 FROM post_pins pp WHERE pp.post_id = p.id AND pp.category_id = `+pinScope+`)`)
+		args = append(args, filter.CategorySlug, filter.CategorySlug)
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeFeedCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return page, err
+		}
+		conditions = append(conditions, `(`+key+` < ? OR (`+key+` = ? AND p.id < ?))`)
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+	}
+
+	query := `
+	SELECT` + postColumns + `, ` + key + `
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE p.deleted_at IS NULL AND p.status = 'published'`
+	for _, condition := range conditions {
+		query += "\n\tAND " + condition
+	}
+	query += "\n\tORDER BY " + key + " DESC, p.id DESC\n\tLIMIT ?"
+
+	// Fetch one extra post to learn whether another page follows
+	args = append(args, filter.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var lastKey interface{}
+	for rows.Next() {
+		var sortKey interface{}
+		post, err := scanPost(rows, &sortKey)
+		if err != nil {
+			return page, err
+		}
+
+		if len(page.Items) == filter.Limit {
+			cursor, err := encodeFeedCursor(feedCursor{Sort: filter.Sort, Key: lastKey, ID: page.Items[len(page.Items)-1].ID})
+			if err != nil {
+				return page, err
+			}
+			page.NextCursor = &cursor
+			break
+		}
+
+		page.Items = append(page.Items, post)
+		lastKey = sortKey
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	rows.Close()
+
+	if pinned && filter.Cursor == "" {
+		page.Pinned, err = getPinnedPosts(db, filter.CategorySlug)
+		if err != nil {
+			return page, err
+		}
+		if err := loadPostDetails(db, page.Pinned, filter.ViewerID); err != nil {
+			return page, err
+		}
+	}
+
+	if err := loadPostDetails(db, page.Items, filter.ViewerID); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+func encodeFeedCursor(cursor feedCursor) (string, error) {
+	// Text keys come back from the driver as bytes; keep them readable
+	if b, ok := cursor.Key.([]byte); ok {
+		cursor.Key = string(b)
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeFeedCursor(encoded, sort string) (feedCursor, error) {
+	var cursor feedCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Key == nil || cursor.ID <= 0 {
+		return cursor, ErrInvalidCursor
+	}
+
+	switch cursor.Key.(type) {
+	case string, float64:
+	default:
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,107 @@
+// backend/models/follow.go
+package models
+
+import (
+	"database/sql"
+)
+
+// followsPost is the SQL condition for whether user, a column or a
+// placeholder, follows post p: as chosen by hand if they did, otherwise if
+// they wrote or commented on it
+func followsPost(user string) string {
+	return `COALESCE(
+		(SELECT f.following FROM post_follows f WHERE f.post_id = p.id AND f.user_id = ` + user + `),
+		p.user_id = ` + user + ` OR EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id AND c.user_id = ` + user + `))`
+}
+
+// FollowPost makes a user follow a post, to be notified of new comments on it
+func FollowPost(db *sql.DB, postID, userID int) error {
+	return setFollowing(db, postID, userID, true)
+}
+
+// UnfollowPost stops a user's notifications for a post, even one they wrote
+// or commented on, until they follow it again
+func UnfollowPost(db *sql.DB, postID, userID int) error {
+	return setFollowing(db, postID, userID, false)
+}
+
+func setFollowing(db *sql.DB, postID, userID int, following bool) error {
+	result, err := db.Exec(`
+		INSERT INTO post_follows (post_id, user_id, following)
+		SELECT id, ?, ? FROM posts WHERE id = ? AND deleted_at IS NULL AND status = 'published'
+		ON CONFLICT (post_id, user_id) DO UPDATE SET following = excluded.following`,
+		userID, following, postID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// GetPostFollowers retrieves the IDs of the users following a post
+func GetPostFollowers(db *sql.DB, postID int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT u.id FROM (
+			SELECT user_id AS id FROM posts WHERE id = ?
+			UNION SELECT user_id FROM comments WHERE post_id = ?
+			UNION SELECT user_id FROM post_follows WHERE post_id = ?
+		) u
+		JOIN posts p ON p.id = ?
+		WHERE p.deleted_at IS NULL AND `+followsPost("u.id")+`
+		ORDER BY u.id`,
+		postID, postID, postID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followers := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		followers = append(followers, id)
+	}
+	return followers, rows.Err()
+}
+
+// loadPostFollows marks the posts viewerID follows
+func loadPostFollows(db *sql.DB, posts []Post, viewerID int) error {
+	if len(posts) == 0 || viewerID == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		index[post.ID] = i
+		ids[i] = post.ID
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT p.id FROM posts p
+		WHERE p.id IN `+in+` AND `+followsPost("?"),
+		append(args, viewerID, viewerID, viewerID)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		posts[index[id]].IsFollowing = true
+	}
+	return rows.Err()
+}
@@ -3,24 +3,48 @@ package models
 
 import (
     "database/sql"
+    "math"
     "time"
 )
 
 type Message struct {
-    ID         int       `json:"id"`
-    SenderID   int       `json:"senderId"`
-    ReceiverID int       `json:"receiverId"`
-    Content    string    `json:"content"`
-    ImageURL   string    `json:"imageUrl"`
-    CreatedAt  time.Time `json:"createdAt"`
-    Sender     User      `json:"sender"`
+    ID          int        `json:"id"`
+    SenderID    int        `json:"senderId"`
+    ReceiverID  int        `json:"receiverId"`
+    Content     string     `json:"content"`
+    ContentHTML string     `json:"contentHtml"`
+    ImageURL    string     `json:"imageUrl"`
+    CreatedAt   time.Time  `json:"createdAt"`
+    ReadAt      *time.Time `json:"readAt"`
+    Sender      User       `json:"sender"`
 }
 
-// Modify CreateMessage function
+// maxPreviewLength is how many characters of the last message a chat summary shows
+const maxPreviewLength = 100
+
+// MessagePreview is a shortened copy of the last message in a conversation
+type MessagePreview struct {
+    ID        int       `json:"id"`
+    SenderID  int       `json:"senderId"`
+    Content   string    `json:"content"`
+    HasImage  bool      `json:"hasImage"`
+    CreatedAt time.Time `json:"createdAt"`
+}
+
+// ChatSummary is a user in the chat list along with the state of the
+// conversation with them
+type ChatSummary struct {
+    User
+    UnreadCount int             `json:"unreadCount"`
+    LastMessage *MessagePreview `json:"lastMessage,omitempty"`
+}
+
+// CreateMessage stores a private message, using message.CreatedAt as its
+// timestamp and message.ContentHTML as its rendered content
 func CreateMessage(db *sql.DB, message Message) (int64, error) {
-    query := `INSERT INTO messages (sender_id, receiver_id, content, image_url) VALUES (?, ?, ?, ?)`
+    query := `INSERT INTO messages (sender_id, receiver_id, content, content_html, image_url, created_at) VALUES (?, ?, ?, ?, ?, ?)`
     
-    result, err := db.Exec(query, message.SenderID, message.ReceiverID, message.Content, message.ImageURL)
+    result, err := db.Exec(query, message.SenderID, message.ReceiverID, message.Content, message.ContentHTML, message.ImageURL, message.CreatedAt)
     if err != nil {
         return 0, err
     }
@@ -28,59 +52,123 @@ func CreateMessage(db *sql.DB, message Message) (int64, error) {
     return result.LastInsertId()
 }
 
-// Update GetMessagesBetweenUsers function to include image_url
-func GetMessagesBetweenUsers(db *sql.DB, userID1, userID2, limit, offset int) ([]Message, error) {
+// MessagePage is one page of a conversation, oldest message first.
+// NextCursor is the message ID to pass as the same cursor to fetch the
+// following page; HasMore reports whether that page has any messages.
+type MessagePage struct {
+    Messages   []Message `json:"messages"`
+    NextCursor *int      `json:"nextCursor"`
+    HasMore    bool      `json:"hasMore"`
+}
+
+// GetMessagesBetweenUsers retrieves a page of the conversation between two users.
+// With afterID set it returns the messages following that ID; otherwise it
+// returns the messages preceding beforeID, or the latest messages when
+// beforeID is 0. Each direction of the conversation is read as a range scan
+// of idx_messages_conversation.
+func GetMessagesBetweenUsers(db *sql.DB, userID1, userID2, limit, beforeID, afterID int) (MessagePage, error) {
+    var page MessagePage
+    
+    order, bound, cmp := "DESC", beforeID, "<"
+    if afterID > 0 {
+        order, bound, cmp = "ASC", afterID, ">"
+    } else if beforeID <= 0 {
+        bound = math.MaxInt64
+    }
+    
+    // Fetch one extra row to learn whether another page follows
     query := `
-    SELECT m.id, m.sender_id, m.receiver_id, m.content, m.image_url, m.created_at,
+    SELECT m.id, m.sender_id, m.receiver_id, m.content, m.content_html, m.image_url, m.created_at, m.read_at,
            u.id, u.nickname, u.first_name, u.last_name
-    FROM messages m
+    FROM (
+        SELECT * FROM (
+            SELECT * FROM messages WHERE sender_id = ? AND receiver_id = ? AND id ` + cmp + ` ?
+            ORDER BY id ` + order + ` LIMIT ?
+        )
+        UNION ALL
+        SELECT * FROM (
+            SELECT * FROM messages WHERE sender_id = ? AND receiver_id = ? AND id ` + cmp + ` ?
+            ORDER BY id ` + order + ` LIMIT ?
+        )
+    ) m
     JOIN users u ON m.sender_id = u.id
-    WHERE (m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?)
-    ORDER BY m.created_at DESC
-    LIMIT ? OFFSET ?`
+    ORDER BY m.id ` + order + `
+    LIMIT ?`
     
-    rows, err := db.Query(query, userID1, userID2, userID2, userID1, limit, offset)
+    rows, err := db.Query(query,
+        userID1, userID2, bound, limit+1,
+        userID2, userID1, bound, limit+1,
+        limit+1)
     if err != nil {
-        return nil, err
+        return page, err
     }
     defer rows.Close()
     
-    var messages []Message
+    messages := []Message{}
     for rows.Next() {
         var message Message
         var sender User
+        var readAt sql.NullTime
         
         err := rows.Scan(
-            &message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.ImageURL, &message.CreatedAt,
+            &message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.ContentHTML, &message.ImageURL, &message.CreatedAt, &readAt,
             &sender.ID, &sender.Nickname, &sender.FirstName, &sender.LastName,
         )
         if err != nil {
-            return nil, err
+            return page, err
         }
         
+        if readAt.Valid {
+            message.ReadAt = &readAt.Time
+        }
         message.Sender = sender
         messages = append(messages, message)
     }
+    if err := rows.Err(); err != nil {
+        return page, err
+    }
     
-    // Reverse the order to get messages in chronological order
-    for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-        messages[i], messages[j] = messages[j], messages[i]
+    if len(messages) > limit {
+        page.HasMore = true
+        messages = messages[:limit]
+    }
+    if len(messages) > 0 {
+        cursor := messages[len(messages)-1].ID
+        page.NextCursor = &cursor
+    }
+    
+    // Older pages were read newest first; return them in chronological order
+    if order == "DESC" {
+        for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+            messages[i], messages[j] = messages[j], messages[i]
+        }
     }
     
-    return messages, nil
+    page.Messages = messages
+    return page, nil
 }
 
-// GetRecentChats retrieves a list of users with whom the current user has exchanged messages
-func GetRecentChats(db *sql.DB, userID int) ([]User, error) {
+// GetRecentChats retrieves the users with whom the current user has exchanged
+// messages, each with the last message and the number of unread messages
+// from them, most recent conversation first
+func GetRecentChats(db *sql.DB, userID int) ([]ChatSummary, error) {
     query := `
-    SELECT DISTINCT 
-        u.id, u.nickname, u.first_name, u.last_name, u.email,
-        (SELECT MAX(created_at) FROM messages 
-         WHERE (sender_id = ? AND receiver_id = u.id) OR (sender_id = u.id AND receiver_id = ?)) as last_message_time
-    FROM users u
-    JOIN messages m ON (m.sender_id = u.id AND m.receiver_id = ?) OR (m.receiver_id = u.id AND m.sender_id = ?)
+    WITH conversations AS (
+        SELECT CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS other_id,
+               MAX(id) AS last_id
+        FROM messages
+        WHERE sender_id = ? OR receiver_id = ?
+        GROUP BY other_id
+    )
+    SELECT u.id, u.nickname, u.first_name, u.last_name, u.email,
+           m.id, m.sender_id, m.content, m.image_url, m.created_at,
+           (SELECT COUNT(*) FROM messages
+            WHERE sender_id = u.id AND receiver_id = ? AND read_at IS NULL) AS unread_count
+    FROM conversations c
+    JOIN users u ON u.id = c.other_id
+    JOIN messages m ON m.id = c.last_id
     WHERE u.id != ?
-    ORDER BY last_message_time DESC`
+    ORDER BY m.id DESC`
     
     rows, err := db.Query(query, userID, userID, userID, userID, userID)
     if err != nil {
@@ -88,20 +176,55 @@ func GetRecentChats(db *sql.DB, userID int) ([]User, error) {
     }
     defer rows.Close()
     
-    var users []User
+    var chats []ChatSummary
     for rows.Next() {
-        var user User
-        var lastMessageTime time.Time
+        var chat ChatSummary
+        var preview MessagePreview
+        var imageURL string
         
-        err := rows.Scan(&user.ID, &user.Nickname, &user.FirstName, &user.LastName, &user.Email, &lastMessageTime)
+        err := rows.Scan(
+            &chat.ID, &chat.Nickname, &chat.FirstName, &chat.LastName, &chat.Email,
+            &preview.ID, &preview.SenderID, &preview.Content, &imageURL, &preview.CreatedAt,
+            &chat.UnreadCount,
+        )
         if err != nil {
             return nil, err
         }
         
-        users = append(users, user)
+        preview.Content = truncate(preview.Content, maxPreviewLength)
+        preview.HasImage = imageURL != ""
+        chat.LastMessage = &preview
+        chats = append(chats, chat)
     }
     
-    return users, nil
+    return chats, nil
+}
+
+// MarkConversationRead marks every unread message sent by otherID to readerID
+// up to and including upToID as read. It returns how many messages changed
+// and the time they were marked.
+func MarkConversationRead(db *sql.DB, readerID, otherID, upToID int) (int64, time.Time, error) {
+    readAt := time.Now().UTC()
+    query := `
+    UPDATE messages SET read_at = ?
+    WHERE receiver_id = ? AND sender_id = ? AND id <= ? AND read_at IS NULL`
+    
+    result, err := db.Exec(query, readAt, readerID, otherID, upToID)
+    if err != nil {
+        return 0, readAt, err
+    }
+    
+    count, err := result.RowsAffected()
+    return count, readAt, err
+}
+
+// truncate shortens s to at most n characters, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+    runes := []rune(s)
+    if len(runes) <= n {
+        return s
+    }
+    return string(runes[:n]) + "…"
 }
 
 // GetUsersWithNoMessages retrieves users with whom current user has no message history
@@ -0,0 +1,183 @@
+// backend/models/moderation.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPostLocked is returned when commenting on a locked post
+	ErrPostLocked = errors.New("post is locked")
+
+	// ErrPostArchived is returned when changing, commenting on or reacting
+	// to an archived post
+	ErrPostArchived = errors.New("post is archived")
+
+	// ErrPostNotInCategory is returned when pinning a post in a category it
+	// isn't filed under
+	ErrPostNotInCategory = errors.New("post is not in category")
+)
+
+// PinPost pins a post to the top of the front page when categoryID is 0, or
+// of a category's feed. Pinned posts are shown by ascending position; pinning
+// an already pinned post moves it.
+func PinPost(db *sql.DB, postID, categoryID, position, moderatorID int) error {
+	var archived bool
+	err := db.QueryRow(`SELECT archived_at IS NOT NULL FROM posts WHERE id = ? AND deleted_at IS NULL AND status = 'published'`,
+		postID).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if archived {
+		return ErrPostArchived
+	}
+
+	if categoryID != 0 {
+		var exists bool
+		err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM post_categories WHERE post_id = ? AND category_id = ?)`,
+			postID, categoryID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrPostNotInCategory
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO post_pins (post_id, category_id, position, pinned_by) VALUES (?, ?, ?, ?)
+		ON CONFLICT (post_id, category_id) DO UPDATE SET position = excluded.position, pinned_by = excluded.pinned_by`,
+		postID, categoryID, position, moderatorID)
+	return err
+}
+
+// UnpinPost takes a post off the front page when categoryID is 0, or off a
+// category's feed. Unpinning a post that isn't pinned there does nothing.
+func UnpinPost(db *sql.DB, postID, categoryID int) error {
+	_, err := db.Exec(`DELETE FROM post_pins WHERE post_id = ? AND category_id = ?`, postID, categoryID)
+	return err
+}
+
+// SetPostLocked locks a post against new comments, or unlocks it
+func SetPostLocked(db *sql.DB, postID int, locked bool) error {
+	var lockedAt interface{}
+	if locked {
+		lockedAt = time.Now().UTC()
+	}
+	return setPostState(db, `UPDATE posts SET locked_at = ? WHERE id = ? AND deleted_at IS NULL AND status = 'published'`,
+		lockedAt, postID)
+}
+
+// SetPostArchived makes a post read-only and takes it out of the default
+// feed, unpinning it everywhere, or restores it
+func SetPostArchived(db *sql.DB, postID int, archived bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now().UTC()
+	}
+	result, err := tx.Exec(`UPDATE posts SET archived_at = ? WHERE id = ? AND deleted_at IS NULL AND status = 'published'`,
+		archivedAt, postID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPostNotFound
+	}
+
+	if archived {
+		if _, err := tx.Exec(`DELETE FROM post_pins WHERE post_id = ?`, postID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func setPostState(db *sql.DB, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// checkPostWritable returns ErrPostArchived or ErrPostLocked if a post can't
+// be commented on, or ErrPostNotFound if it doesn't exist or isn't published
+func checkPostWritable(db *sql.DB, postID int) error {
+	var locked, archived bool
+	err := db.QueryRow(`
+		SELECT locked_at IS NOT NULL, archived_at IS NOT NULL FROM posts
+		WHERE id = ? AND deleted_at IS NULL AND status = 'published'`, postID).Scan(&locked, &archived)
+	if err == sql.ErrNoRows {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if archived {
+		return ErrPostArchived
+	}
+	if locked {
+		return ErrPostLocked
+	}
+	return nil
+}
+
+// pinScope is the category_id of the pins shown on a feed, given the feed's
+// category slug twice: 0 for the front page, or the category's ID
+const pinScope = `(CASE WHEN ? = '' THEN 0 ELSE (SELECT id FROM categories WHERE slug = ?) END)`
+
+// getPinnedPosts retrieves the posts pinned on the front page when
+// categorySlug is empty, or in a category, in order
+func getPinnedPosts(db *sql.DB, categorySlug string) ([]Post, error) {
+	query := `
+	SELECT` + postColumns + `
+	FROM post_pins pp
+	JOIN posts p ON pp.post_id = p.id
+	JOIN users u ON p.user_id = u.id
+	WHERE pp.category_id = ` + pinScope + `
+	AND p.deleted_at IS NULL AND p.status = 'published' AND p.archived_at IS NULL
+	AND (pp.category_id = 0 OR EXISTS (
+		SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = pp.category_id))
+	ORDER BY pp.position, pp.created_at, p.id`
+
+	rows, err := db.Query(query, categorySlug, categorySlug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,236 @@
+// backend/models/notification.go
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Kinds of notification
+const (
+	NotificationNewComment = "new_comment"
+)
+
+// Notification tells a user something happened that they asked to hear
+// about, such as Actor commenting on a post they follow
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	Type      string    `json:"type"`
+	PostID    int       `json:"postId"`
+	PostTitle string    `json:"postTitle"`
+	CommentID int       `json:"commentId,omitempty"`
+	Actor     User      `json:"actor"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NotificationPage is one page of a user's notifications, newest first, with
+// how many of all their notifications are unread. NextCursor is nil on the
+// last page.
+type NotificationPage struct {
+	Items       []Notification `json:"items"`
+	NextCursor  *int           `json:"nextCursor"`
+	UnreadCount int            `json:"unreadCount"`
+}
+
+// notificationColumns selects a notification with its post's title and its
+// actor; scan it with scanNotification
+const notificationColumns = `
+	n.id, n.user_id, n.type, n.post_id, p.title, COALESCE(n.comment_id, 0), n.read_at IS NOT NULL, n.created_at,
+	u.id, u.nickname`
+
+func scanNotification(row interface{ Scan(...interface{}) error }) (Notification, error) {
+	var n Notification
+	err := row.Scan(&n.ID, &n.UserID, &n.Type, &n.PostID, &n.PostTitle, &n.CommentID, &n.Read, &n.CreatedAt,
+		&n.Actor.ID, &n.Actor.Nickname)
+	return n, err
+}
+
+// NotifyNewComment stores a notification of a new comment for each follower
+// of its post except the commenter, and returns them so they can be pushed to
+// their users, with how many notifications each of those users has unread
+func NotifyNewComment(db *sql.DB, commentID int) ([]Notification, map[int]int, error) {
+	var postID, actorID int
+	err := db.QueryRow(`SELECT post_id, user_id FROM comments WHERE id = ?`, commentID).Scan(&postID, &actorID)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	followers, err := GetPostFollowers(db, postID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var ids, recipients []int
+	for _, userID := range followers {
+		if userID == actorID {
+			continue
+		}
+		result, err := tx.Exec(`INSERT INTO notifications (user_id, type, post_id, comment_id, actor_id) VALUES (?, ?, ?, ?, ?)`,
+			userID, NotificationNewComment, postID, commentID, actorID)
+		if err != nil {
+			return nil, nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, int(id))
+		recipients = append(recipients, userID)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	notifications, err := getNotificationsByIDs(db, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	unread, err := countUnreadNotificationsByUser(db, recipients)
+	return notifications, unread, err
+}
+
+// GetNotifications retrieves a page of a user's notifications, newest first,
+// after the notification with ID before when it is set. Notifications about
+// deleted posts are left out.
+func GetNotifications(db *sql.DB, userID, before, limit int) (NotificationPage, error) {
+	page := NotificationPage{Items: []Notification{}}
+
+	query := `
+	SELECT` + notificationColumns + `
+	FROM notifications n
+	JOIN posts p ON n.post_id = p.id
+	JOIN users u ON n.actor_id = u.id
+	WHERE n.user_id = ? AND p.deleted_at IS NULL`
+	args := []interface{}{userID}
+	if before > 0 {
+		query += "\n\tAND n.id < ?"
+		args = append(args, before)
+	}
+	query += "\n\tORDER BY n.id DESC\n\tLIMIT ?"
+
+	// Fetch one extra notification to learn whether another page follows
+	args = append(args, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if len(page.Items) == limit {
+			cursor := page.Items[len(page.Items)-1].ID
+			page.NextCursor = &cursor
+			break
+		}
+
+		n, err := scanNotification(rows)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, n)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	rows.Close()
+
+	page.UnreadCount, err = CountUnreadNotifications(db, userID)
+	return page, err
+}
+
+// CountUnreadNotifications counts a user's unread notifications
+func CountUnreadNotifications(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM notifications n
+		JOIN posts p ON n.post_id = p.id
+		WHERE n.user_id = ? AND n.read_at IS NULL AND p.deleted_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// countUnreadNotificationsByUser counts the unread notifications of each of
+// several users, by user ID
+func countUnreadNotificationsByUser(db *sql.DB, userIDs []int) (map[int]int, error) {
+	counts := make(map[int]int)
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	in, args := inClause(userIDs)
+	rows, err := db.Query(`
+		SELECT n.user_id, COUNT(*) FROM notifications n
+		JOIN posts p ON n.post_id = p.id
+		WHERE n.user_id IN `+in+` AND n.read_at IS NULL AND p.deleted_at IS NULL
+		GROUP BY n.user_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	return counts, rows.Err()
+}
+
+// MarkNotificationsRead marks a user's notifications up to upToID as read, or
+// all of them when upToID is 0, and returns how many remain unread
+func MarkNotificationsRead(db *sql.DB, userID, upToID int) (int, error) {
+	query := `UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`
+	args := []interface{}{time.Now().UTC(), userID}
+	if upToID > 0 {
+		query += ` AND id <= ?`
+		args = append(args, upToID)
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		return 0, err
+	}
+
+	return CountUnreadNotifications(db, userID)
+}
+
+// getNotificationsByIDs retrieves notifications in the order of ids
+func getNotificationsByIDs(db *sql.DB, ids []int) ([]Notification, error) {
+	notifications := []Notification{}
+	if len(ids) == 0 {
+		return notifications, nil
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT`+notificationColumns+`
+		FROM notifications n
+		JOIN posts p ON n.post_id = p.id
+		JOIN users u ON n.actor_id = u.id
+		WHERE n.id IN `+in+`
+		ORDER BY n.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
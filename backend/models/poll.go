@@ -0,0 +1,342 @@
+// backend/models/poll.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Limits on the options of a poll
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 10
+)
+
+// Poll is a question attached to a post. Voters may pick one option, or
+// several when Multiple is set. Who voted for what is only shown for polls
+// that aren't Anonymous.
+type Poll struct {
+	ID        int          `json:"id"`
+	PostID    int          `json:"postId"`
+	Question  string       `json:"question"`
+	Multiple  bool         `json:"multiple"`
+	Anonymous bool         `json:"anonymous"`
+	ClosesAt  *time.Time   `json:"closesAt,omitempty"`
+	Closed    bool         `json:"closed"`
+	Options   []PollOption `json:"options"`
+
+	// Number of users who voted, which for multiple choice polls can be
+	// fewer than the number of votes
+	TotalVoters int `json:"totalVoters"`
+
+	// Options the viewing user voted for
+	MyVotes []int `json:"myVotes"`
+}
+
+// PollOption is one answer to a poll with its tally. Voters lists the
+// nicknames of those who picked it, for polls that aren't anonymous.
+type PollOption struct {
+	ID     int      `json:"id"`
+	Text   string   `json:"text"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+var (
+	// ErrPollNotFound is returned when a poll does not exist or its post has
+	// been deleted
+	ErrPollNotFound = errors.New("poll not found")
+
+	// ErrPollClosed is returned when voting in a poll after it closed
+	ErrPollClosed = errors.New("poll is closed")
+
+	// ErrInvalidVote is returned for a ballot with options from another poll,
+	// or with more than one option in a single choice poll
+	ErrInvalidVote = errors.New("invalid vote")
+)
+
+// createPoll attaches a poll with its options, in order, to a new post
+func createPoll(tx *sql.Tx, postID int, poll Poll) error {
+	var closesAt interface{}
+	if poll.ClosesAt != nil {
+		closesAt = poll.ClosesAt.UTC().Format(feedTimeFormat)
+	}
+
+	result, err := tx.Exec(`INSERT INTO polls (post_id, question, multiple, anonymous, closes_at) VALUES (?, ?, ?, ?, ?)`,
+		postID, poll.Question, poll.Multiple, poll.Anonymous, closesAt)
+	if err != nil {
+		return err
+	}
+	pollID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for i, option := range poll.Options {
+		_, err := tx.Exec(`INSERT INTO poll_options (poll_id, position, text) VALUES (?, ?, ?)`, pollID, i, option.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPoll retrieves a poll with its tallies, and the votes of viewerID
+func GetPoll(db *sql.DB, pollID int, viewerID int) (Poll, error) {
+	polls, err := queryPolls(db, `p.id = ?`, pollID)
+	if err != nil {
+		return Poll{}, err
+	}
+	if len(polls) == 0 {
+		return Poll{}, ErrPollNotFound
+	}
+
+	if err := loadPollTallies(db, polls, viewerID); err != nil {
+		return Poll{}, err
+	}
+	return polls[0], nil
+}
+
+// Vote replaces a user's ballot in a poll with the given options and returns
+// the ID of the poll's post. A single choice poll takes exactly one option.
+func Vote(db *sql.DB, pollID, userID int, optionIDs []int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	postID, multiple, err := checkPollOpen(tx, pollID)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[int]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		seen[id] = true
+	}
+	if len(seen) == 0 || (!multiple && len(seen) > 1) {
+		return 0, ErrInvalidVote
+	}
+
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE poll_id = ? AND user_id = ?`, pollID, userID); err != nil {
+		return 0, err
+	}
+	for id := range seen {
+		result, err := tx.Exec(`
+			INSERT INTO poll_votes (poll_id, option_id, user_id)
+			SELECT poll_id, id, ? FROM poll_options WHERE id = ? AND poll_id = ?`,
+			userID, id, pollID)
+		if err != nil {
+			return 0, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, ErrInvalidVote
+		}
+	}
+
+	return postID, tx.Commit()
+}
+
+// Unvote takes back a user's ballot in a poll and returns the ID of the
+// poll's post
+func Unvote(db *sql.DB, pollID, userID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	postID, _, err := checkPollOpen(tx, pollID)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE poll_id = ? AND user_id = ?`, pollID, userID); err != nil {
+		return 0, err
+	}
+
+	return postID, tx.Commit()
+}
+
+// checkPollOpen returns the post ID of a poll that can still be voted in and
+// whether it is multiple choice
+func checkPollOpen(tx *sql.Tx, pollID int) (int, bool, error) {
+	var postID int
+	var multiple, closed, archived bool
+	err := tx.QueryRow(`
+		SELECT p.post_id, p.multiple, p.closes_at IS NOT NULL AND p.closes_at <= ?, posts.archived_at IS NOT NULL
+		FROM polls p
+		JOIN posts ON p.post_id = posts.id
+		WHERE p.id = ? AND posts.deleted_at IS NULL AND posts.status = 'published'`,
+		time.Now().UTC().Format(feedTimeFormat), pollID).Scan(&postID, &multiple, &closed, &archived)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrPollNotFound
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if archived {
+		return 0, false, ErrPostArchived
+	}
+	if closed {
+		return 0, false, ErrPollClosed
+	}
+	return postID, multiple, nil
+}
+
+// loadPostPolls fills in the poll of each post that has one, with its
+// tallies and the votes of viewerID
+func loadPostPolls(db *sql.DB, posts []Post, viewerID int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		index[post.ID] = i
+		ids[i] = post.ID
+	}
+
+	in, args := inClause(ids)
+	polls, err := queryPolls(db, `p.post_id IN `+in, args...)
+	if err != nil {
+		return err
+	}
+	if err := loadPollTallies(db, polls, viewerID); err != nil {
+		return err
+	}
+
+	for i := range polls {
+		posts[index[polls[i].PostID]].Poll = &polls[i]
+	}
+	return nil
+}
+
+// queryPolls retrieves the polls matching a condition on polls p, without
+// their options
+func queryPolls(db *sql.DB, condition string, args ...interface{}) ([]Poll, error) {
+	query := `
+	SELECT p.id, p.post_id, p.question, p.multiple, p.anonymous, p.closes_at
+	FROM polls p
+	WHERE ` + condition + `
+	ORDER BY p.id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	polls := []Poll{}
+	for rows.Next() {
+		var poll Poll
+		var closesAt sql.NullTime
+		if err := rows.Scan(&poll.ID, &poll.PostID, &poll.Question, &poll.Multiple, &poll.Anonymous, &closesAt); err != nil {
+			return nil, err
+		}
+		if closesAt.Valid {
+			poll.ClosesAt = &closesAt.Time
+			poll.Closed = !closesAt.Time.After(now)
+		}
+		poll.Options = []PollOption{}
+		poll.MyVotes = []int{}
+		polls = append(polls, poll)
+	}
+
+	return polls, rows.Err()
+}
+
+// loadPollTallies fills in the options of each poll with their votes, who
+// cast them when the poll isn't anonymous, and the votes of viewerID
+func loadPollTallies(db *sql.DB, polls []Poll, viewerID int) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(polls))
+	ids := make([]int, len(polls))
+	for i, poll := range polls {
+		index[poll.ID] = i
+		ids[i] = poll.ID
+	}
+	in, args := inClause(ids)
+
+	rows, err := db.Query(`
+		SELECT poll_id, id, text FROM poll_options
+		WHERE poll_id IN `+in+`
+		ORDER BY poll_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pollID int
+		var option PollOption
+		if err := rows.Scan(&pollID, &option.ID, &option.Text); err != nil {
+			return err
+		}
+		i := index[pollID]
+		polls[i].Options = append(polls[i].Options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// Options are tallied through pointers, taken once all have been appended
+	options := make(map[int]*PollOption)
+	for i := range polls {
+		for j := range polls[i].Options {
+			options[polls[i].Options[j].ID] = &polls[i].Options[j]
+		}
+	}
+
+	rows, err = db.Query(`
+		SELECT v.poll_id, v.option_id, v.user_id, u.nickname, p.anonymous
+		FROM poll_votes v
+		JOIN polls p ON v.poll_id = p.id
+		JOIN users u ON v.user_id = u.id
+		WHERE v.poll_id IN `+in+`
+		ORDER BY v.created_at, u.nickname`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	voters := make(map[int]map[int]bool, len(polls))
+	for rows.Next() {
+		var pollID, optionID, userID int
+		var nickname string
+		var anonymous bool
+		if err := rows.Scan(&pollID, &optionID, &userID, &nickname, &anonymous); err != nil {
+			return err
+		}
+		i := index[pollID]
+
+		if voters[pollID] == nil {
+			voters[pollID] = make(map[int]bool)
+		}
+		if !voters[pollID][userID] {
+			voters[pollID][userID] = true
+			polls[i].TotalVoters++
+		}
+		if userID == viewerID && viewerID != 0 {
+			polls[i].MyVotes = append(polls[i].MyVotes, optionID)
+		}
+		options[optionID].Votes++
+		if !anonymous {
+			options[optionID].Voters = append(options[optionID].Voters, nickname)
+		}
+	}
+	return rows.Err()
+}
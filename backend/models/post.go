@@ -3,97 +3,264 @@ package models
 
 import (
     "database/sql"
+    "errors"
     "time"
+    
+    "forum/backend/markdown"
 )
 
 type Post struct {
-    ID        int       `json:"id"`
-    UserID    int       `json:"userId"`
-    Title     string    `json:"title"`
-    Content   string    `json:"content"`
-    Category  string    `json:"category"`
-    CreatedAt time.Time `json:"createdAt"`
-    User      User      `json:"user"`
-    Comments  []Comment `json:"comments,omitempty"`
+    ID           int          `json:"id"`
+    UserID       int          `json:"userId"`
+    Title        string       `json:"title"`
+    Content      string       `json:"content"`
+    ContentHTML  string       `json:"contentHtml"`
+    Category     string       `json:"category"`
+    CreatedAt    time.Time    `json:"createdAt"`
+    Edited       bool         `json:"edited"`
+    EditedAt     *time.Time   `json:"editedAt,omitempty"`
+    Status       string       `json:"status"`
+    PublishAt    *time.Time   `json:"publishAt,omitempty"`
+    Pinned       bool         `json:"pinned"`
+    Locked       bool         `json:"locked"`
+    Archived     bool         `json:"archived"`
+    User         User         `json:"user"`
+    Categories   []Category   `json:"categories"`
+    Tags         []string     `json:"tags"`
+    Poll         *Poll        `json:"poll,omitempty"`
+    Attachments  []Attachment `json:"attachments,omitempty"`
+    CommentCount int          `json:"commentCount"`
+    Views        int          `json:"views"`
+    IsBookmarked bool         `json:"isBookmarked"`
+    IsFollowing  bool         `json:"isFollowing"`
+    Reactions
+    
+    // First page of the comment thread, when requested; CommentsCursor
+    // loads more top-level comments
+    Comments       []Comment `json:"comments,omitempty"`
+    CommentsCursor *int      `json:"commentsCursor,omitempty"`
 }
 
-// CreatePost creates a new post
-func CreatePost(db *sql.DB, post Post) (int64, error) {
-    query := `INSERT INTO posts (user_id, title, content, category) VALUES (?, ?, ?, ?)`
+// ErrPostNotFound is returned when a post does not exist or has been deleted
+var ErrPostNotFound = errors.New("post not found")
+
+// Limits on the text of a post or comment, in characters
+const (
+    MaxTitleLength   = 200
+    MaxContentLength = 20000
+)
+
+// postColumns selects a post with its author; scan it with scanPost
+const postColumns = `
+    p.id, p.user_id, p.title, p.content, p.content_html, p.category, p.created_at, p.edited_at,
+    p.status, p.publish_at,
+    EXISTS (SELECT 1 FROM post_pins pp WHERE pp.post_id = p.id), p.locked_at IS NOT NULL, p.archived_at IS NOT NULL,
+    (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id), p.view_count,
+    u.id, u.nickname, u.email`
+
+// scanPost reads a row selected with postColumns, followed by any extra columns
+func scanPost(row interface{ Scan(...interface{}) error }, extra ...interface{}) (Post, error) {
+    var post Post
+    var user User
+    var editedAt, publishAt sql.NullTime
     
-    result, err := db.Exec(query, post.UserID, post.Title, post.Content, post.Category)
+    dest := []interface{}{
+        &post.ID, &post.UserID, &post.Title, &post.Content, &post.ContentHTML, &post.Category, &post.CreatedAt, &editedAt,
+        &post.Status, &publishAt,
+        &post.Pinned, &post.Locked, &post.Archived,
+        &post.CommentCount, &post.Views,
+        &user.ID, &user.Nickname, &user.Email,
+    }
+    err := row.Scan(append(dest, extra...)...)
     if err != nil {
-        return 0, err
+        return post, err
     }
     
-    return result.LastInsertId()
+    if editedAt.Valid {
+        post.Edited = true
+        post.EditedAt = &editedAt.Time
+    }
+    if publishAt.Valid {
+        post.PublishAt = &publishAt.Time
+    }
+    post.User = user
+    return post, nil
 }
 
-// GetAllPosts retrieves all posts with their authors
-func GetAllPosts(db *sql.DB) ([]Post, error) {
-    query := `
-    SELECT p.id, p.user_id, p.title, p.content, p.category, p.created_at,
-           u.id, u.nickname, u.email
-    FROM posts p
-    JOIN users u ON p.user_id = u.id
-    ORDER BY p.created_at DESC`
+// CreatePost creates a new post with the given categories and tags, and its
+// poll and attachments if it has them, rendering its Markdown content. It
+// returns ErrAttachmentNotOwned or ErrAttachmentInUse if an attachment isn't
+// the author's to attach.
+func CreatePost(db *sql.DB, post Post) (int64, error) {
+    tx, err := db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+    
+    query := `INSERT INTO posts (user_id, title, content, content_html, category) VALUES (?, ?, ?, ?, ?)`
     
-    rows, err := db.Query(query)
+    result, err := tx.Exec(query, post.UserID, post.Title, post.Content, markdown.Render(post.Content), CategoryNames(post.Categories))
     if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-    
-    var posts []Post
-    for rows.Next() {
-        var post Post
-        var user User
-        
-        err := rows.Scan(
-            &post.ID, &post.UserID, &post.Title, &post.Content, &post.Category, &post.CreatedAt,
-            &user.ID, &user.Nickname, &user.Email,
-        )
-        if err != nil {
-            return nil, err
+        return 0, err
+    }
+    
+    postID, err := result.LastInsertId()
+    if err != nil {
+        return 0, err
+    }
+    
+    if err := setPostCategories(tx, int(postID), post.Categories); err != nil {
+        return 0, err
+    }
+    if err := setPostTags(tx, int(postID), post.Tags); err != nil {
+        return 0, err
+    }
+    if post.Poll != nil {
+        if err := createPoll(tx, int(postID), *post.Poll); err != nil {
+            return 0, err
         }
-        
-        post.User = user
-        posts = append(posts, post)
+    }
+    if err := attachFiles(tx, "post_id", int(postID), post.UserID, post.Attachments); err != nil {
+        return 0, err
     }
     
-    return posts, nil
+    return postID, tx.Commit()
 }
 
-// GetPostByID retrieves a post by its ID with comments
-func GetPostByID(db *sql.DB, postID int) (Post, error) {
-    var post Post
-    
+// GetPostByID retrieves a post by its ID, with reactions, poll votes and
+// bookmarks as seen by viewerID. Drafts are only found for their author.
+func GetPostByID(db *sql.DB, postID int, viewerID int) (Post, error) {
     // Get post with author
     postQuery := `
-    SELECT p.id, p.user_id, p.title, p.content, p.category, p.created_at,
-           u.id, u.nickname, u.email
+    SELECT` + postColumns + `
     FROM posts p
     JOIN users u ON p.user_id = u.id
-    WHERE p.id = ?`
+    WHERE p.id = ? AND p.deleted_at IS NULL AND (p.status = 'published' OR p.user_id = ?)`
     
-    row := db.QueryRow(postQuery, postID)
-    var user User
+    post, err := scanPost(db.QueryRow(postQuery, postID, viewerID))
+    if err == sql.ErrNoRows {
+        return post, ErrPostNotFound
+    }
+    if err != nil {
+        return post, err
+    }
     
-    err := row.Scan(
-        &post.ID, &post.UserID, &post.Title, &post.Content, &post.Category, &post.CreatedAt,
-        &user.ID, &user.Nickname, &user.Email,
+    posts := []Post{post}
+    if err := loadPostDetails(db, posts, viewerID); err != nil {
+        return post, err
+    }
+    
+    return posts[0], nil
+}
+
+// loadPostDetails fills in everything about each post that isn't selected
+// with postColumns: categories, tags, attachments, and reactions, poll votes,
+// bookmarks and follows as seen by viewerID
+func loadPostDetails(db *sql.DB, posts []Post, viewerID int) error {
+    if err := loadPostCategories(db, posts); err != nil {
+        return err
+    }
+    if err := loadPostTags(db, posts); err != nil {
+        return err
+    }
+    if err := loadPostReactions(db, posts, viewerID); err != nil {
+        return err
+    }
+    if err := loadPostPolls(db, posts, viewerID); err != nil {
+        return err
+    }
+    if err := loadPostAttachments(db, posts); err != nil {
+        return err
+    }
+    if err := loadPostBookmarks(db, posts, viewerID); err != nil {
+        return err
+    }
+    return loadPostFollows(db, posts, viewerID)
+}
+
+// UpdatePost replaces a published post's title, content, categories and
+// tags. The version being replaced is saved to post_revisions first, credited
+// to whoever wrote it. Drafts are changed with SaveDraft instead, and archived
+// posts are read-only.
+func UpdatePost(db *sql.DB, post Post, editorID int) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    
+    var current PostRevision
+    var editedBy sql.NullInt64
+    var editedAt sql.NullTime
+    var archived bool
+    query := `
+    SELECT p.title, p.content, p.user_id, p.created_at, p.edited_by, p.edited_at, p.archived_at IS NOT NULL,
+           COALESCE((SELECT group_concat(name, ', ') FROM (
+               SELECT c.name FROM post_categories pc
+               JOIN categories c ON pc.category_id = c.id
+               WHERE pc.post_id = p.id
+               ORDER BY c.sort_order, c.name)), '')
+    FROM posts p WHERE p.id = ? AND p.deleted_at IS NULL AND p.status = 'published'`
+    err = tx.QueryRow(query, post.ID).Scan(
+        &current.Title, &current.Content, &current.EditorID, &current.CreatedAt, &editedBy, &editedAt, &archived, &current.Category,
     )
+    if err == sql.ErrNoRows {
+        return ErrPostNotFound
+    }
     if err != nil {
-        return post, err
+        return err
+    }
+    if archived {
+        return ErrPostArchived
+    }
+    
+    // The current version was written by the last editor, or the author if never edited
+    if editedBy.Valid {
+        current.EditorID = int(editedBy.Int64)
+    }
+    if editedAt.Valid {
+        current.CreatedAt = editedAt.Time
     }
-    post.User = user
     
-    // Get comments
-    comments, err := GetCommentsByPostID(db, postID)
+    _, err = tx.Exec(`INSERT INTO post_revisions (post_id, title, content, category, editor_id, created_at)
+                      VALUES (?, ?, ?, ?, ?, ?)`,
+        post.ID, current.Title, current.Content, current.Category, current.EditorID, current.CreatedAt)
     if err != nil {
-        return post, err
+        return err
     }
-    post.Comments = comments
     
-    return post, nil
-}
\ No newline at end of file
+    _, err = tx.Exec(`UPDATE posts SET title = ?, content = ?, content_html = ?, category = ?, edited_by = ?, edited_at = ? WHERE id = ?`,
+        post.Title, post.Content, markdown.Render(post.Content), CategoryNames(post.Categories), editorID, time.Now().UTC(), post.ID)
+    if err != nil {
+        return err
+    }
+    
+    if err := setPostCategories(tx, post.ID, post.Categories); err != nil {
+        return err
+    }
+    if err := setPostTags(tx, post.ID, post.Tags); err != nil {
+        return err
+    }
+    
+    return tx.Commit()
+}
+
+// DeletePost withdraws a post. The row and its revisions are kept for the
+// record but the post no longer appears anywhere.
+func DeletePost(db *sql.DB, postID int) error {
+    query := `UPDATE posts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+    result, err := db.Exec(query, time.Now().UTC(), postID)
+    if err != nil {
+        return err
+    }
+    
+    count, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if count == 0 {
+        return ErrPostNotFound
+    }
+    return nil
+}
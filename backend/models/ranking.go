@@ -0,0 +1,154 @@
+// backend/models/ranking.go
+package models
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"time"
+)
+
+// Time windows the feed can be limited to, counting back from now
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+)
+
+var windowDurations = map[string]time.Duration{
+	WindowDay:   24 * time.Hour,
+	WindowWeek:  7 * 24 * time.Hour,
+	WindowMonth: 30 * 24 * time.Hour,
+}
+
+// How much each kind of engagement adds to a post's score. Dislikes take
+// away as much as likes add.
+const (
+	viewWeight     = 1
+	commentWeight  = 5
+	reactionWeight = 3
+)
+
+// hotGravity is how quickly a post's hot score decays with age; with the
+// score divided by (hours + 2) ^ gravity, a post needs about 7 times the
+// engagement to keep up with one a third of its age
+const hotGravity = 1.8
+
+// ValidWindow reports whether window is one of the feed's time windows
+func ValidWindow(window string) bool {
+	_, ok := windowDurations[window]
+	return ok
+}
+
+// RecordView counts a view of a published post, once per viewer. viewer
+// identifies a user or an anonymous visitor; see the post_views table.
+func RecordView(db *sql.DB, postID int, viewer string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO post_views (post_id, viewer)
+		SELECT id, ? FROM posts WHERE id = ? AND deleted_at IS NULL AND status = 'published'`,
+		viewer, postID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE posts SET view_count = view_count + 1 WHERE id = ?`, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// postScore is how much engagement a post has had: its views, comments and
+// net likes, weighted
+func postScore(views, comments, likes, dislikes int) float64 {
+	return float64(views*viewWeight + comments*commentWeight + (likes-dislikes)*reactionWeight)
+}
+
+// hotScore decays a post's score with its age, so recent engagement ranks
+// above old engagement
+func hotScore(score float64, age time.Duration) float64 {
+	hours := math.Max(age.Hours(), 0)
+	return score / math.Pow(hours+2, hotGravity)
+}
+
+// RefreshRankings recomputes the scores the hot and top sorts order by, for
+// every published post
+func RefreshRankings(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`
+		SELECT p.id, p.created_at, p.view_count,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id),
+			(SELECT COUNT(*) FROM reactions r WHERE r.target_type = 'post' AND r.target_id = p.id AND r.reaction = 'like'),
+			(SELECT COUNT(*) FROM reactions r WHERE r.target_type = 'post' AND r.target_id = p.id AND r.reaction = 'dislike')
+		FROM posts p
+		WHERE p.deleted_at IS NULL AND p.status = 'published'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type ranking struct {
+		postID     int
+		score, hot float64
+	}
+	var rankings []ranking
+	for rows.Next() {
+		var postID, views, comments, likes, dislikes int
+		var createdAt time.Time
+		if err := rows.Scan(&postID, &createdAt, &views, &comments, &likes, &dislikes); err != nil {
+			return err
+		}
+		score := postScore(views, comments, likes, dislikes)
+		rankings = append(rankings, ranking{postID, score, hotScore(score, now.Sub(createdAt))})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Replace every score so deleted and unpublished posts drop out
+	if _, err := tx.Exec(`DELETE FROM post_scores`); err != nil {
+		return err
+	}
+	refreshedAt := now.UTC().Format(feedTimeFormat)
+	for _, r := range rankings {
+		_, err := tx.Exec(`INSERT INTO post_scores (post_id, score, hot, refreshed_at) VALUES (?, ?, ?, ?)`,
+			r.postID, r.score, r.hot, refreshedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// StartRankingRefresher recomputes post rankings now and then every
+// interval. Posts published in between rank as if they had no engagement
+// until the next refresh. It blocks, so run it in its own goroutine.
+func StartRankingRefresher(db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := time.Now(); ; now = <-ticker.C {
+		if err := RefreshRankings(db, now); err != nil {
+			log.Printf("Error refreshing post rankings: %v", err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// backend/models/reaction.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// Reactions a user can leave
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// Things that can be reacted to
+const (
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+// ErrReactionTargetNotFound is returned when reacting to a post or comment
+// that doesn't exist, has been deleted or isn't published yet
+var ErrReactionTargetNotFound = errors.New("reaction target not found")
+
+// Reactions holds the reaction counts of a post or comment and how the
+// viewing user reacted to it, if they did
+type Reactions struct {
+	Likes      int    `json:"likes"`
+	Dislikes   int    `json:"dislikes"`
+	MyReaction string `json:"myReaction,omitempty"`
+}
+
+// ToggleReaction sets a user's reaction to a post or comment. Reacting the
+// same way twice takes the reaction back, and reacting the other way replaces
+// it. It returns the ID of the post the target belongs to and its new counts,
+// or ErrPostArchived if that post is archived.
+func ToggleReaction(db *sql.DB, userID int, targetType string, targetID int, reaction string) (int, Reactions, error) {
+	var reactions Reactions
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, reactions, err
+	}
+	defer tx.Rollback()
+
+	var postID int
+	var archived bool
+	switch targetType {
+	case TargetPost:
+		err = tx.QueryRow(`SELECT id, archived_at IS NOT NULL FROM posts WHERE id = ? AND deleted_at IS NULL AND status = 'published'`,
+			targetID).Scan(&postID, &archived)
+	case TargetComment:
+		err = tx.QueryRow(`
+			SELECT c.post_id, p.archived_at IS NOT NULL FROM comments c
+			JOIN posts p ON c.post_id = p.id
+			WHERE c.id = ? AND p.deleted_at IS NULL AND p.status = 'published'`, targetID).Scan(&postID, &archived)
+	default:
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		return 0, reactions, ErrReactionTargetNotFound
+	}
+	if err != nil {
+		return 0, reactions, err
+	}
+	if archived {
+		return 0, reactions, ErrPostArchived
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT reaction FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ?`,
+		userID, targetType, targetID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, reactions, err
+	}
+
+	if current == reaction {
+		_, err = tx.Exec(`DELETE FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ?`,
+			userID, targetType, targetID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO reactions (user_id, target_type, target_id, reaction) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, target_type, target_id) DO UPDATE SET reaction = excluded.reaction`,
+			userID, targetType, targetID, reaction)
+	}
+	if err != nil {
+		return 0, reactions, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, reactions, err
+	}
+
+	counts, err := loadReactions(db, targetType, []int{targetID}, userID)
+	if err != nil {
+		return 0, reactions, err
+	}
+	return postID, counts[targetID], nil
+}
+
+// loadReactions counts the reactions to each of the given posts or comments,
+// including how viewerID reacted. Targets nobody reacted to are left out.
+func loadReactions(db *sql.DB, targetType string, ids []int, viewerID int) (map[int]Reactions, error) {
+	reactions := make(map[int]Reactions, len(ids))
+	if len(ids) == 0 {
+		return reactions, nil
+	}
+
+	in, args := inClause(ids)
+	query := `
+	SELECT target_id,
+	       SUM(reaction = 'like'),
+	       SUM(reaction = 'dislike'),
+	       COALESCE(MAX(CASE WHEN user_id = ? THEN reaction END), '')
+	FROM reactions
+	WHERE target_type = ? AND target_id IN ` + in + `
+	GROUP BY target_id`
+
+	rows, err := db.Query(query, append([]interface{}{viewerID, targetType}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var r Reactions
+		if err := rows.Scan(&id, &r.Likes, &r.Dislikes, &r.MyReaction); err != nil {
+			return nil, err
+		}
+		reactions[id] = r
+	}
+
+	return reactions, rows.Err()
+}
+
+// loadPostReactions fills in the reactions of each post
+func loadPostReactions(db *sql.DB, posts []Post, viewerID int) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	reactions, err := loadReactions(db, TargetPost, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].ID]
+	}
+	return nil
+}
+
+// loadCommentReactions fills in the reactions of each comment
+func loadCommentReactions(db *sql.DB, comments []Comment, viewerID int) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	reactions, err := loadReactions(db, TargetComment, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = reactions[comments[i].ID]
+	}
+	return nil
+}
+
+// inClause builds "(?, ?, ...)" and its arguments for an IN condition
+func inClause(ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
@@ -0,0 +1,203 @@
+// backend/models/revision.go
+package models
+
+import (
+    "database/sql"
+    "strings"
+    "time"
+)
+
+// PostRevision is one version of a post. Revisions are numbered from 1, the
+// original, up to the current version.
+type PostRevision struct {
+    Number    int       `json:"number"`
+    PostID    int       `json:"postId"`
+    Title     string    `json:"title"`
+    Content   string    `json:"content"`
+    Category  string    `json:"category"`
+    EditorID  int       `json:"editorId"`
+    Editor    User      `json:"editor"`
+    CreatedAt time.Time `json:"createdAt"`
+    Current   bool      `json:"current"`
+}
+
+// GetPostRevisions retrieves every version of a post, oldest first, ending
+// with the current one
+func GetPostRevisions(db *sql.DB, postID int) ([]PostRevision, error) {
+    // Stored revisions come in the order they were replaced, then the
+    // current version
+    query := `
+    SELECT r.post_id, r.title, r.content, r.category, r.editor_id, r.created_at AS created_at,
+           u.id, u.nickname, 0 AS is_current, r.id AS seq
+    FROM post_revisions r
+    JOIN posts p ON r.post_id = p.id AND p.deleted_at IS NULL AND p.status = 'published'
+    JOIN users u ON r.editor_id = u.id
+    WHERE r.post_id = ?
+    UNION ALL
+    SELECT p.id, p.title, p.content, p.category, COALESCE(p.edited_by, p.user_id), COALESCE(p.edited_at, p.created_at),
+           u.id, u.nickname, 1, 0
+    FROM posts p
+    JOIN users u ON COALESCE(p.edited_by, p.user_id) = u.id
+    WHERE p.id = ? AND p.deleted_at IS NULL AND p.status = 'published'
+    ORDER BY is_current, created_at, seq`
+    
+    rows, err := db.Query(query, postID, postID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var revisions []PostRevision
+    for rows.Next() {
+        var revision PostRevision
+        var editor User
+        var isCurrent, seq int
+        
+        err := rows.Scan(
+            &revision.PostID, &revision.Title, &revision.Content, &revision.Category, &revision.EditorID, &revision.CreatedAt,
+            &editor.ID, &editor.Nickname, &isCurrent, &seq,
+        )
+        if err != nil {
+            return nil, err
+        }
+        
+        revision.Number = len(revisions) + 1
+        revision.Editor = editor
+        revisions = append(revisions, revision)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    
+    // The post itself comes last; with no rows at all it is missing or deleted
+    if len(revisions) == 0 {
+        return nil, ErrPostNotFound
+    }
+    // The current version's categories come from the post itself
+    posts := []Post{{ID: postID}}
+    if err := loadPostCategories(db, posts); err != nil {
+        return nil, err
+    }
+    revisions[len(revisions)-1].Category = posts[0].Category
+    revisions[len(revisions)-1].Current = true
+    
+    return revisions, nil
+}
+
+// DiffLine is one line of a diff between two revisions
+type DiffLine struct {
+    // "equal", "insert" or "delete"
+    Op   string `json:"op"`
+    Text string `json:"text"`
+}
+
+// RevisionDiff holds line diffs of each field between two revisions
+type RevisionDiff struct {
+    From     int        `json:"from"`
+    To       int        `json:"to"`
+    Title    []DiffLine `json:"title"`
+    Content  []DiffLine `json:"content"`
+    Category []DiffLine `json:"category"`
+}
+
+// DiffRevisions compares two revisions of the same post
+func DiffRevisions(from, to PostRevision) RevisionDiff {
+    return RevisionDiff{
+        From:     from.Number,
+        To:       to.Number,
+        Title:    DiffLines(from.Title, to.Title),
+        Content:  DiffLines(from.Content, to.Content),
+        Category: DiffLines(from.Category, to.Category),
+    }
+}
+
+// maxDiffCells bounds the table DiffLines builds to find the longest common
+// subsequence, about 32MB; the lines left after trimming what both texts
+// start and end with must fit in it
+const maxDiffCells = 1 << 22
+
+// DiffLines returns the line by line changes that turn a into b, using the
+// longest common subsequence of their lines. When the changed part is too
+// large to compare line by line, it is shown as every old line deleted and
+// every new line inserted.
+func DiffLines(a, b string) []DiffLine {
+    oldLines := strings.Split(a, "\n")
+    newLines := strings.Split(b, "\n")
+    
+    // Lines both texts start or end with are left as they are
+    prefix := 0
+    for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+        prefix++
+    }
+    suffix := 0
+    for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+        oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+        suffix++
+    }
+    
+    var diff []DiffLine
+    for _, line := range oldLines[:prefix] {
+        diff = append(diff, DiffLine{Op: "equal", Text: line})
+    }
+    diff = append(diff, diffChanged(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])...)
+    for _, line := range oldLines[len(oldLines)-suffix:] {
+        diff = append(diff, DiffLine{Op: "equal", Text: line})
+    }
+    
+    return diff
+}
+
+// diffChanged diffs the lines between the common start and end of two texts
+func diffChanged(oldLines, newLines []string) []DiffLine {
+    var diff []DiffLine
+    if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+        for _, line := range oldLines {
+            diff = append(diff, DiffLine{Op: "delete", Text: line})
+        }
+        for _, line := range newLines {
+            diff = append(diff, DiffLine{Op: "insert", Text: line})
+        }
+        return diff
+    }
+    
+    // lcs[i][j] is the length of the common subsequence of oldLines[i:] and newLines[j:]
+    lcs := make([][]int, len(oldLines)+1)
+    for i := range lcs {
+        lcs[i] = make([]int, len(newLines)+1)
+    }
+    for i := len(oldLines) - 1; i >= 0; i-- {
+        for j := len(newLines) - 1; j >= 0; j-- {
+            if oldLines[i] == newLines[j] {
+                lcs[i][j] = lcs[i+1][j+1] + 1
+            } else if lcs[i+1][j] >= lcs[i][j+1] {
+                lcs[i][j] = lcs[i+1][j]
+            } else {
+                lcs[i][j] = lcs[i][j+1]
+            }
+        }
+    }
+    
+    i, j := 0, 0
+    for i < len(oldLines) && j < len(newLines) {
+        switch {
+        case oldLines[i] == newLines[j]:
+            diff = append(diff, DiffLine{Op: "equal", Text: oldLines[i]})
+            i++
+            j++
+        case lcs[i+1][j] >= lcs[i][j+1]:
+            diff = append(diff, DiffLine{Op: "delete", Text: oldLines[i]})
+            i++
+        default:
+            diff = append(diff, DiffLine{Op: "insert", Text: newLines[j]})
+            j++
+        }
+    }
+    for ; i < len(oldLines); i++ {
+        diff = append(diff, DiffLine{Op: "delete", Text: oldLines[i]})
+    }
+    for ; j < len(newLines); j++ {
+        diff = append(diff, DiffLine{Op: "insert", Text: newLines[j]})
+    }
+    
+    return diff
+}
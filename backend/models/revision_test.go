@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// formatDiff writes a diff one line per entry, prefixed with " " for equal,
+// "-" for deleted and "+" for inserted lines
+func formatDiff(diff []DiffLine) string {
+	prefixes := map[string]string{"equal": " ", "delete": "-", "insert": "+"}
+	var b strings.Builder
+	for _, line := range diff {
+		b.WriteString(prefixes[line.Op] + line.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"same", "a\nb", "a\nb", " a\n b\n"},
+		{"empty", "", "", " \n"},
+		{"from empty", "", "a", "-\n+a\n"},
+		{"changed line", "a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n"},
+		{"inserted line", "a\nc", "a\nb\nc", " a\n+b\n c\n"},
+		{"deleted line", "a\nb\nc", "a\nc", " a\n-b\n c\n"},
+		{"moved line", "a\nb\nc\nd", "b\nc\na\nd", "-a\n b\n c\n+a\n d\n"},
+		{"repeated lines", "x\nx\ny", "x\ny\nx", " x\n-x\n y\n+x\n"},
+	}
+	for _, tt := range tests {
+		if got := formatDiff(DiffLines(tt.a, tt.b)); got != tt.want {
+			t.Errorf("%s: DiffLines(%q, %q) =\n%s\nwant\n%s", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// Too many changed lines to compare one by one: the change is shown as
+	// a whole, but the common start and end still match
+	var oldLines, newLines []string
+	for i := 0; i < 5000; i++ {
+		oldLines = append(oldLines, "old "+strconv.Itoa(i))
+		newLines = append(newLines, "new "+strconv.Itoa(i))
+	}
+	a := "start\n" + strings.Join(oldLines, "\n") + "\nend"
+	b := "start\n" + strings.Join(newLines, "\n") + "\nend"
+
+	diff := DiffLines(a, b)
+	if len(diff) != 10002 {
+		t.Fatalf("got %d lines, want 10002", len(diff))
+	}
+	if diff[0] != (DiffLine{Op: "equal", Text: "start"}) || diff[len(diff)-1] != (DiffLine{Op: "equal", Text: "end"}) {
+		t.Errorf("common start and end not kept: %v ... %v", diff[0], diff[len(diff)-1])
+	}
+	if diff[1] != (DiffLine{Op: "delete", Text: "old 0"}) || diff[5001] != (DiffLine{Op: "insert", Text: "new 0"}) {
+		t.Errorf("changed lines not replaced as a whole: %v, %v", diff[1], diff[5001])
+	}
+}
@@ -0,0 +1,328 @@
+// backend/models/search.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kinds of search result
+const (
+	SearchPosts    = "post"
+	SearchComments = "comment"
+	SearchMessages = "message"
+)
+
+// MaxSearchOffset is the furthest into the results a search may page. Every
+// kind of result up to the offset is ranked for each page, so deep pages cost
+// more the further they go.
+const MaxSearchOffset = 1000
+
+// Snippets mark matches with control characters that don't occur in normal
+// text, turned into <mark> tags once the snippet has been escaped
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// ErrEmptySearch is returned for a query with no words to search for
+var ErrEmptySearch = errors.New("search query has no words")
+
+// SearchFilter selects one page of search results
+type SearchFilter struct {
+	Query string
+
+	// Kinds of result to include; all when empty
+	Types []string
+
+	// Posts in this category and comments on them. Messages have no
+	// category, so they are left out when this is set.
+	CategorySlug string
+
+	// Written or sent by this user
+	AuthorID int
+
+	// Messages are only searched within this user's own conversations, and
+	// not at all for anonymous searches
+	ViewerID int
+
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a post, comment or message matching a search
+type SearchResult struct {
+	Type   string `json:"type"`
+	ID     int    `json:"id"`
+	PostID int    `json:"postId,omitempty"`
+
+	// Title of the post, or of the post a comment is on
+	Title string `json:"title,omitempty"`
+
+	// Matching text as HTML, with matches wrapped in <mark>
+	Snippet string `json:"snippet"`
+
+	// Relevance; higher is better
+	Score float64 `json:"score"`
+
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Author of a post or comment, or sender of a message
+	User User `json:"user"`
+
+	// The other participant of a message's conversation
+	OtherUserID int `json:"otherUserId,omitempty"`
+}
+
+// SearchPage is one page of search results, best first. NextOffset is nil
+// on the last page.
+type SearchPage struct {
+	Items      []SearchResult `json:"items"`
+	NextOffset *int           `json:"nextOffset"`
+}
+
+// Search finds posts, comments and messages matching a query, ranked by relevance
+func Search(db *sql.DB, filter SearchFilter) (SearchPage, error) {
+	page := SearchPage{Items: []SearchResult{}}
+
+	match := ftsQuery(filter.Query)
+	if match == "" {
+		return page, ErrEmptySearch
+	}
+
+	include := func(kind string) bool {
+		if len(filter.Types) == 0 {
+			return true
+		}
+		for _, t := range filter.Types {
+			if t == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Each kind is ranked separately and the results merged; any page needs
+	// at most this many of each
+	window := filter.Offset + filter.Limit + 1
+
+	var results []SearchResult
+	if include(SearchPosts) {
+		posts, err := searchPosts(db, match, filter, window)
+		if err != nil {
+			return page, err
+		}
+		results = append(results, posts...)
+	}
+	if include(SearchComments) {
+		comments, err := searchComments(db, match, filter, window)
+		if err != nil {
+			return page, err
+		}
+		results = append(results, comments...)
+	}
+	if include(SearchMessages) && filter.ViewerID > 0 && filter.CategorySlug == "" {
+		messages, err := searchMessages(db, match, filter, window)
+		if err != nil {
+			return page, err
+		}
+		results = append(results, messages...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if filter.Offset < len(results) {
+		results = results[filter.Offset:]
+		if len(results) > filter.Limit {
+			results = results[:filter.Limit]
+			if next := filter.Offset + filter.Limit; next <= MaxSearchOffset {
+				page.NextOffset = &next
+			}
+		}
+		page.Items = results
+	}
+
+	return page, nil
+}
+
+func searchPosts(db *sql.DB, match string, filter SearchFilter, limit int) ([]SearchResult, error) {
+	query := `
+	SELECT p.id, p.title, snippet(posts_fts, -1, ?, ?, '…', 16), bm25(posts_fts, 5.0, 1.0), p.created_at,
+	       u.id, u.nickname
+	FROM posts_fts
+	JOIN posts p ON p.id = posts_fts.rowid
+	JOIN users u ON p.user_id = u.id
+	WHERE posts_fts MATCH ? AND p.deleted_at IS NULL AND p.status = 'published'`
+	args := []interface{}{matchStart, matchEnd, match}
+
+	if filter.CategorySlug != "" {
+		query += `
+	AND EXISTS (
+		SELECT 1 FROM post_categories pc
+		JOIN categories cat ON pc.category_id = cat.id
+		WHERE pc.post_id = p.id AND cat.slug = ?)`
+		args = append(args, filter.CategorySlug)
+	}
+	if filter.AuthorID > 0 {
+		query += `
+	AND p.user_id = ?`
+		args = append(args, filter.AuthorID)
+	}
+	query += `
+	ORDER BY bm25(posts_fts, 5.0, 1.0)
+	LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		result := SearchResult{Type: SearchPosts}
+		var rank float64
+		err := rows.Scan(&result.ID, &result.Title, &result.Snippet, &rank, &result.CreatedAt,
+			&result.User.ID, &result.User.Nickname)
+		if err != nil {
+			return nil, err
+		}
+		result.PostID = result.ID
+		result.Score = -rank
+		result.Snippet = highlight(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func searchComments(db *sql.DB, match string, filter SearchFilter, limit int) ([]SearchResult, error) {
+	query := `
+	SELECT c.id, c.post_id, p.title, snippet(comments_fts, 0, ?, ?, '…', 16), bm25(comments_fts), c.created_at,
+	       u.id, u.nickname
+	FROM comments_fts
+	JOIN comments c ON c.id = comments_fts.rowid
+	JOIN posts p ON c.post_id = p.id
+	JOIN users u ON c.user_id = u.id
+	WHERE comments_fts MATCH ? AND p.deleted_at IS NULL AND p.status = 'published'`
+	args := []interface{}{matchStart, matchEnd, match}
+
+	if filter.CategorySlug != "" {
+		query += `
+	AND EXISTS (
+		SELECT 1 FROM post_categories pc
+		JOIN categories cat ON pc.category_id = cat.id
+		WHERE pc.post_id = p.id AND cat.slug = ?)`
+		args = append(args, filter.CategorySlug)
+	}
+	if filter.AuthorID > 0 {
+		query += `
+	AND c.user_id = ?`
+		args = append(args, filter.AuthorID)
+	}
+	query += `
+	ORDER BY bm25(comments_fts)
+	LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		result := SearchResult{Type: SearchComments}
+		var rank float64
+		err := rows.Scan(&result.ID, &result.PostID, &result.Title, &result.Snippet, &rank, &result.CreatedAt,
+			&result.User.ID, &result.User.Nickname)
+		if err != nil {
+			return nil, err
+		}
+		result.Score = -rank
+		result.Snippet = highlight(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// searchMessages only ever looks at conversations the viewer takes part in
+func searchMessages(db *sql.DB, match string, filter SearchFilter, limit int) ([]SearchResult, error) {
+	query := `
+	SELECT m.id, snippet(messages_fts, 0, ?, ?, '…', 16), bm25(messages_fts), m.created_at,
+	       u.id, u.nickname,
+	       CASE WHEN m.sender_id = ? THEN m.receiver_id ELSE m.sender_id END
+	FROM messages_fts
+	JOIN messages m ON m.id = messages_fts.rowid
+	JOIN users u ON m.sender_id = u.id
+	WHERE messages_fts MATCH ? AND (m.sender_id = ? OR m.receiver_id = ?)`
+	args := []interface{}{matchStart, matchEnd, filter.ViewerID, match, filter.ViewerID, filter.ViewerID}
+
+	if filter.AuthorID > 0 {
+		query += `
+	AND m.sender_id = ?`
+		args = append(args, filter.AuthorID)
+	}
+	query += `
+	ORDER BY bm25(messages_fts)
+	LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		result := SearchResult{Type: SearchMessages}
+		var rank float64
+		err := rows.Scan(&result.ID, &result.Snippet, &rank, &result.CreatedAt,
+			&result.User.ID, &result.User.Nickname, &result.OtherUserID)
+		if err != nil {
+			return nil, err
+		}
+		result.Score = -rank
+		result.Snippet = highlight(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// ftsQuery turns what a user typed into an FTS5 query matching every word,
+// the last one as a prefix so results show up while typing. Words are quoted
+// so nothing the user types is taken as FTS5 syntax.
+func ftsQuery(input string) string {
+	words := strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"`
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
+
+// highlight escapes a snippet for HTML and turns its match markers into <mark> tags
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, matchStart, "<mark>")
+	return strings.ReplaceAll(snippet, matchEnd, "</mark>")
+}
@@ -2,44 +2,195 @@
 package models
 
 import (
+    "crypto/sha256"
     "database/sql"
-    "github.com/gofrs/uuid"
+    "encoding/hex"
+    "errors"
+    "log"
     "time"
+
+    "github.com/gofrs/uuid"
+)
+
+const (
+    // SessionIdleTimeout is how long a session survives without any activity
+    SessionIdleTimeout = 7 * 24 * time.Hour
+
+    // SessionMaxLifetime caps the total age of a session, however active it is
+    SessionMaxLifetime = 30 * 24 * time.Hour
+
+    // sessionTouchInterval limits how often activity is written back to the database
+    sessionTouchInterval = time.Minute
 )
 
+// ErrSessionExpired is returned when a session exists but is past its expiry
+var ErrSessionExpired = errors.New("session expired")
+
 type Session struct {
-    ID        string    `json:"id"`
-    UserID    int       `json:"userId"`
-    CreatedAt time.Time `json:"createdAt"`
+    ID         string    `json:"id"`
+    UserID     int       `json:"userId"`
+    CreatedAt  time.Time `json:"createdAt"`
+    LastSeenAt time.Time `json:"lastSeenAt"`
+    ExpiresAt  time.Time `json:"expiresAt"`
+    UserAgent  string    `json:"userAgent"`
+    IPAddress  string    `json:"ipAddress"`
 }
 
-// CreateSession creates a new session for a user
-func CreateSession(db *sql.DB, userID int) (string, error) {
+// CreateSession creates a new session for a user, recording the device it was created from
+func CreateSession(db *sql.DB, userID int, userAgent, ipAddress string) (Session, error) {
     uuid, err := uuid.NewV4()
     if err != nil {
-        return "", err
+        return Session{}, err
     }
     
-    sessionID := uuid.String()
+    now := time.Now().UTC()
+    session := Session{
+        ID:         uuid.String(),
+        UserID:     userID,
+        CreatedAt:  now,
+        LastSeenAt: now,
+        ExpiresAt:  now.Add(SessionIdleTimeout),
+        UserAgent:  userAgent,
+        IPAddress:  ipAddress,
+    }
     
-    query := `INSERT INTO sessions (id, user_id) VALUES (?, ?)`
-    _, err = db.Exec(query, sessionID, userID)
+    query := `INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+    _, err = db.Exec(query, session.ID, session.UserID, session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+        session.UserAgent, session.IPAddress)
     if err != nil {
-        return "", err
+        return Session{}, err
     }
     
-    return sessionID, nil
+    return session, nil
 }
 
-// GetSessionByID retrieves a session by its ID
+// GetSessionByID retrieves a session by its ID, returning ErrSessionExpired
+// (and removing the row) if the session is no longer valid
 func GetSessionByID(db *sql.DB, sessionID string) (Session, error) {
     var session Session
-    query := `SELECT id, user_id, created_at FROM sessions WHERE id = ?`
+    query := `SELECT id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address
+              FROM sessions WHERE id = ?`
     
     row := db.QueryRow(query, sessionID)
-    err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt)
+    err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+        &session.UserAgent, &session.IPAddress)
+    if err != nil {
+        return session, err
+    }
+    
+    if session.Expired(time.Now()) {
+        if err := DeleteSession(db, sessionID); err != nil {
+            log.Printf("Error deleting expired session: %v", err)
+        }
+        return session, ErrSessionExpired
+    }
+    
+    return session, nil
+}
+
+// Expired reports whether the session is past its idle expiry or absolute lifetime
+func (s Session) Expired(now time.Time) bool {
+    return !now.Before(s.ExpiresAt) || !now.Before(s.CreatedAt.Add(SessionMaxLifetime))
+}
+
+// PublicID returns a stable identifier for the session that is safe to show
+// to the client. The raw session ID is the cookie secret and never leaves the
+// Set-Cookie header.
+func (s Session) PublicID() string {
+    sum := sha256.Sum256([]byte(s.ID))
+    return hex.EncodeToString(sum[:8])
+}
+
+// GetSessionsByUserID retrieves a user's unexpired sessions, most recently used first
+func GetSessionsByUserID(db *sql.DB, userID int) ([]Session, error) {
+    query := `
+    SELECT id, user_id, created_at, last_seen_at, expires_at, user_agent, ip_address
+    FROM sessions
+    WHERE user_id = ?
+    ORDER BY last_seen_at DESC`
+    
+    rows, err := db.Query(query, userID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    now := time.Now()
+    var sessions []Session
+    for rows.Next() {
+        var session Session
+        
+        err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt,
+            &session.UserAgent, &session.IPAddress)
+        if err != nil {
+            return nil, err
+        }
+        
+        if session.Expired(now) {
+            continue
+        }
+        sessions = append(sessions, session)
+    }
+    
+    return sessions, rows.Err()
+}
+
+// DeleteOtherSessions removes every session of a user except keepID and
+// returns the IDs of the sessions it removed
+func DeleteOtherSessions(db *sql.DB, userID int, keepID string) ([]string, error) {
+    rows, err := db.Query(`SELECT id FROM sessions WHERE user_id = ? AND id != ?`, userID, keepID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
     
-    return session, err
+    var sessionIDs []string
+    for rows.Next() {
+        var sessionID string
+        if err := rows.Scan(&sessionID); err != nil {
+            return nil, err
+        }
+        sessionIDs = append(sessionIDs, sessionID)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+    
+    for _, sessionID := range sessionIDs {
+        if err := DeleteSession(db, sessionID); err != nil {
+            return nil, err
+        }
+    }
+    
+    return sessionIDs, nil
+}
+
+// RenewSession slides the session's expiry forward after activity. The expiry
+// never moves past the absolute lifetime cap. Writes are skipped if the
+// session was already touched recently; the returned bool reports whether
+// the session was renewed.
+func RenewSession(db *sql.DB, session Session) (Session, bool, error) {
+    now := time.Now().UTC()
+    if now.Sub(session.LastSeenAt) < sessionTouchInterval {
+        return session, false, nil
+    }
+    
+    expiresAt := now.Add(SessionIdleTimeout)
+    if limit := session.CreatedAt.Add(SessionMaxLifetime); expiresAt.After(limit) {
+        expiresAt = limit
+    }
+    
+    query := `UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?`
+    _, err := db.Exec(query, now, expiresAt, session.ID)
+    if err != nil {
+        return session, false, err
+    }
+    
+    session.LastSeenAt = now
+    session.ExpiresAt = expiresAt
+    return session, true, nil
 }
 
 // DeleteSession removes a session from the database
@@ -47,4 +198,60 @@ func DeleteSession(db *sql.DB, sessionID string) error {
     query := `DELETE FROM sessions WHERE id = ?`
     _, err := db.Exec(query, sessionID)
     return err
-}
\ No newline at end of file
+}
+
+// PurgeExpiredSessions removes every session past its expiry or lifetime cap
+// and returns the IDs of the sessions it removed
+func PurgeExpiredSessions(db *sql.DB) ([]string, error) {
+    now := time.Now().UTC()
+    query := `SELECT id FROM sessions WHERE expires_at <= ? OR created_at <= ?`
+    
+    rows, err := db.Query(query, now, now.Add(-SessionMaxLifetime))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    
+    var sessionIDs []string
+    for rows.Next() {
+        var sessionID string
+        if err := rows.Scan(&sessionID); err != nil {
+            return nil, err
+        }
+        sessionIDs = append(sessionIDs, sessionID)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+    
+    for _, sessionID := range sessionIDs {
+        if err := DeleteSession(db, sessionID); err != nil {
+            return nil, err
+        }
+    }
+    
+    return sessionIDs, nil
+}
+
+// StartSessionReaper purges expired sessions every interval and calls
+// onPurge for each one so live connections using it can be closed. It
+// blocks, so run it in its own goroutine.
+func StartSessionReaper(db *sql.DB, interval time.Duration, onPurge func(sessionID string)) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    
+    for range ticker.C {
+        sessionIDs, err := PurgeExpiredSessions(db)
+        if err != nil {
+            log.Printf("Error purging expired sessions: %v", err)
+            continue
+        }
+        if len(sessionIDs) > 0 {
+            log.Printf("Purged %d expired sessions", len(sessionIDs))
+        }
+        for _, sessionID := range sessionIDs {
+            onPurge(sessionID)
+        }
+    }
+}
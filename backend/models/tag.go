@@ -0,0 +1,263 @@
+// backend/models/tag.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Limits on the tags of a post
+const (
+	MaxPostTags  = 5
+	MaxTagLength = 32
+)
+
+type Tag struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	PostCount int    `json:"postCount"`
+}
+
+var (
+	// ErrTagNotFound is returned when a tag does not exist
+	ErrTagNotFound = errors.New("tag not found")
+
+	// ErrTagExists is returned when renaming a tag to the name of another one;
+	// merge them instead
+	ErrTagExists = errors.New("tag already exists")
+
+	// ErrInvalidTag is returned for a tag that is empty or too long once
+	// normalized
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrTooManyTags is returned when a post is given more than MaxPostTags tags
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// NormalizeTag turns a tag as typed into its stored form: lower case, with
+// a leading # dropped, spaces and underscores turned into dashes and anything
+// but letters, digits, dashes and dots removed, e.g. "Release 2.3" becomes
+// "release-2.3"
+func NormalizeTag(tag string) string {
+	return strings.Trim(normalizeTagPrefix(tag), "-.")
+}
+
+// normalizeTagPrefix normalizes the start of a tag being typed, keeping a
+// trailing dash or dot so "release-" still matches "release-2.3"
+func normalizeTagPrefix(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(tag) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.':
+			b.WriteRune(r)
+			dash = false
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			if !dash && b.Len() > 0 {
+				b.WriteRune('-')
+				dash = true
+			}
+		}
+	}
+	return b.String()
+}
+
+// NormalizeTags normalizes the tags of a post, dropping blank ones and
+// duplicates. It returns ErrInvalidTag or ErrTooManyTags if they can't be
+// used.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		name := NormalizeTag(tag)
+		if name == "" || len([]rune(name)) > MaxTagLength {
+			return nil, ErrInvalidTag
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	if len(normalized) > MaxPostTags {
+		return nil, ErrTooManyTags
+	}
+	return normalized, nil
+}
+
+// GetTags retrieves tags starting with prefix, or every tag when it is
+// empty, most used first. Only published posts count towards usage.
+func GetTags(db *sql.DB, prefix string, limit int) ([]Tag, error) {
+	prefix = normalizeTagPrefix(prefix)
+
+	query := `
+	SELECT t.id, t.name, COUNT(p.id) AS uses
+	FROM tags t
+	LEFT JOIN post_tags pt ON pt.tag_id = t.id
+	LEFT JOIN posts p ON pt.post_id = p.id AND p.deleted_at IS NULL AND p.status = 'published'
+	WHERE substr(t.name, 1, length(?)) = ?
+	GROUP BY t.id
+	ORDER BY uses DESC, t.name
+	LIMIT ?`
+
+	rows, err := db.Query(query, prefix, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTagByID retrieves a single tag with its usage count
+func GetTagByID(db *sql.DB, id int) (Tag, error) {
+	var tag Tag
+	query := `
+	SELECT t.id, t.name, COUNT(p.id)
+	FROM tags t
+	LEFT JOIN post_tags pt ON pt.tag_id = t.id
+	LEFT JOIN posts p ON pt.post_id = p.id AND p.deleted_at IS NULL AND p.status = 'published'
+	WHERE t.id = ?
+	GROUP BY t.id`
+
+	err := db.QueryRow(query, id).Scan(&tag.ID, &tag.Name, &tag.PostCount)
+	if err == sql.ErrNoRows {
+		return tag, ErrTagNotFound
+	}
+	return tag, err
+}
+
+// RenameTag changes a tag's name on every post that has it. It returns
+// ErrTagExists if another tag already has the new name.
+func RenameTag(db *sql.DB, id int, name string) error {
+	var existing int
+	err := db.QueryRow(`SELECT id FROM tags WHERE name = ? AND id != ?`, name, id).Scan(&existing)
+	if err == nil {
+		return ErrTagExists
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	result, err := db.Exec(`UPDATE tags SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// MergeTags retags every post tagged sourceID with targetID and removes the
+// source tag. The two tags must differ.
+func MergeTags(db *sql.DB, sourceID, targetID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range []int{sourceID, targetID} {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tags WHERE id = ?)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrTagNotFound
+		}
+	}
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO post_tags (post_id, tag_id) SELECT post_id, ? FROM post_tags WHERE tag_id = ?`,
+		targetID, sourceID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE tag_id = ?`, sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id = ?`, sourceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setPostTags replaces the tags of a post, creating any that are new. The
+// names must already be normalized.
+func setPostTags(tx *sql.Tx, postID int, names []string) error {
+	if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, name); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, postID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPostTags fills in the tag names of each post, alphabetically
+func loadPostTags(db *sql.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(posts))
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		index[post.ID] = i
+		ids[i] = post.ID
+		posts[i].Tags = []string{}
+	}
+
+	in, args := inClause(ids)
+	query := `
+	SELECT pt.post_id, t.name
+	FROM post_tags pt
+	JOIN tags t ON pt.tag_id = t.id
+	WHERE pt.post_id IN ` + in + `
+	ORDER BY t.name`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var name string
+		if err := rows.Scan(&postID, &name); err != nil {
+			return err
+		}
+		i := index[postID]
+		posts[i].Tags = append(posts[i].Tags, name)
+	}
+	return rows.Err()
+}
@@ -15,9 +15,27 @@ type User struct {
     LastName  string    `json:"lastName"`
     Email     string    `json:"email"`
     Password  string    `json:"-"` // Don't send password to client
+    Role      string    `json:"role,omitempty"`
     CreatedAt time.Time `json:"createdAt"`
 }
 
+// User roles. Moderators and admins are promoted directly in the database.
+const (
+    RoleUser      = "user"
+    RoleModerator = "moderator"
+    RoleAdmin     = "admin"
+)
+
+// IsModerator reports whether the user may moderate other users' content
+func (u User) IsModerator() bool {
+    return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
+// IsAdmin reports whether the user may manage site settings
+func (u User) IsAdmin() bool {
+    return u.Role == RoleAdmin
+}
+
 type UserProfile struct {
     UserID      int       `json:"userId"`
     Bio         string    `json:"bio"`
@@ -43,12 +61,12 @@ func RegisterUser(db *sql.DB, user User, hashedPassword string) (int64, error) {
 // GetUserByNicknameOrEmail finds user by nickname or email for login
 func GetUserByNicknameOrEmail(db *sql.DB, identifier string) (User, error) {
     var user User
-    query := `SELECT id, nickname, age, gender, first_name, last_name, email, password, created_at
+    query := `SELECT id, nickname, age, gender, first_name, last_name, email, password, role, created_at
               FROM users 
               WHERE nickname = ? OR email = ?`
     
     row := db.QueryRow(query, identifier, identifier)
-    err := row.Scan(&user.ID, &user.Nickname, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.CreatedAt)
+    err := row.Scan(&user.ID, &user.Nickname, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
     
     return user, err
 }
@@ -56,12 +74,12 @@ func GetUserByNicknameOrEmail(db *sql.DB, identifier string) (User, error) {
 // GetUserByID retrieves a user by their ID
 func GetUserByID(db *sql.DB, id int) (User, error) {
     var user User
-    query := `SELECT id, nickname, age, gender, first_name, last_name, email, created_at
+    query := `SELECT id, nickname, age, gender, first_name, last_name, email, role, created_at
               FROM users 
               WHERE id = ?`
     
     row := db.QueryRow(query, id)
-    err := row.Scan(&user.ID, &user.Nickname, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email, &user.CreatedAt)
+    err := row.Scan(&user.ID, &user.Nickname, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.CreatedAt)
     
     return user, err
 }
@@ -85,7 +103,7 @@ func GetUserProfile(db *sql.DB, userID int) (UserProfile, error) {
     }
     
     // Get post count
-    postQuery := `SELECT COUNT(*) FROM posts WHERE user_id = ?`
+    postQuery := `SELECT COUNT(*) FROM posts WHERE user_id = ? AND deleted_at IS NULL AND status = 'published'`
     row = db.QueryRow(postQuery, userID)
     err = row.Scan(&profile.PostCount)
     if err != nil {
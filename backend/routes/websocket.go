@@ -2,9 +2,13 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
 
+	"forum/backend/middleware"
+	"forum/backend/models"
 	websocketPkg "forum/backend/websocket"
 
 	"github.com/gorilla/websocket"
@@ -13,24 +17,48 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for now
-	},
+	CheckOrigin:     sameOrigin,
 }
 
-// HandleWebSocket upgrades HTTP connection to WebSocket and registers client
+// sameOrigin rejects upgrades started by pages on other sites. The socket is
+// authenticated by the session cookie alone, which the browser sends no matter
+// which page opens it. Clients that aren't browsers send no Origin and are let
+// through.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// HandleWebSocket authenticates the session cookie, upgrades the HTTP
+// connection to WebSocket and registers the client for that user
 func HandleWebSocket(hub *websocketPkg.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Get user ID from query parameter
-		userIDStr := r.URL.Query().Get("userId")
-		if userIDStr == "" {
-			http.Error(w, "User ID is required", http.StatusBadRequest)
+		// Resolve the user from the session cookie; the connection is
+		// bound to this session and closed when it ends
+		cookie, err := r.Cookie("session_id")
+		if err != nil {
+			w.Header().Set(middleware.SessionStatusHeader, "missing")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		userID, err := strconv.Atoi(userIDStr)
+		session, err := models.GetSessionByID(hub.DB, cookie.Value)
+		if errors.Is(err, models.ErrSessionExpired) {
+			middleware.ClearSessionCookie(w)
+			w.Header().Set(middleware.SessionStatusHeader, "expired")
+			http.Error(w, "Session expired", http.StatusUnauthorized)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			w.Header().Set(middleware.SessionStatusHeader, "invalid")
+			http.Error(w, "Invalid session", http.StatusUnauthorized)
 			return
 		}
 
@@ -43,10 +71,11 @@ func HandleWebSocket(hub *websocketPkg.Hub) http.HandlerFunc {
 
 		// Create client
 		client := &websocketPkg.Client{
-			Hub:    hub,
-			Conn:   conn,
-			Send:   make(chan []byte, 256),
-			UserID: userID,
+			Hub:       hub,
+			Conn:      conn,
+			Send:      make(chan []byte, hub.Config.SendBufferSize),
+			UserID:    session.UserID,
+			SessionID: session.ID,
 		}
 
 		// Register client
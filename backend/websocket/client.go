@@ -6,6 +6,7 @@ import (
     "log"
     "time"
 
+    "forum/backend/models"
     "github.com/gorilla/websocket"
 )
 
@@ -29,7 +30,12 @@ type Client struct {
     Conn    *websocket.Conn
     Send    chan []byte
     UserID  int
-    IsTyping bool
+    SessionID string
+
+    // Frames the hub is holding back while Send is full, and when the
+    // client fell behind. Owned by the hub's Run goroutine.
+    pending   []queuedFrame
+    slowSince time.Time
 }
 
 // Message represents different types of messages exchanged over WebSocket
@@ -40,12 +46,16 @@ type Message struct {
 
 // ChatMessage represents a private message between users
 type ChatMessage struct {
+    ID         int       `json:"id"`
     SenderID   int       `json:"senderId"`
     ReceiverID int       `json:"receiverId"`
     Content    string    `json:"content"`
     ImageURL   string    `json:"imageUrl"`
     CreatedAt  time.Time `json:"createdAt"`
     SenderName string    `json:"senderName"`
+    
+    // Set by the server from Content when delivering the message
+    ContentHTML string `json:"contentHtml,omitempty"`
 }
 
 // PostMessage represents a new post notification
@@ -53,7 +63,7 @@ type PostMessage struct {
     PostID int `json:"postId"`
 }
 
-// CommentMessage represents a new comment notification
+// CommentMessage announces a new comment to the followers of its post
 type CommentMessage struct {
     PostID    int `json:"postId"`
     CommentID int `json:"commentId"`
@@ -66,6 +76,52 @@ type TypingMessage struct {
     IsTyping   bool `json:"isTyping"`
 }
 
+// MarkReadMessage marks the conversation with another user as read up to a message
+type MarkReadMessage struct {
+    UserID int `json:"userId"`
+    UpToID int `json:"upToId"`
+}
+
+// ReadReceiptMessage tells a sender their messages up to UpToID were read
+type ReadReceiptMessage struct {
+    ReaderID int       `json:"readerId"`
+    SenderID int       `json:"senderId"`
+    UpToID   int       `json:"upToId"`
+    ReadAt   time.Time `json:"readAt"`
+}
+
+// ReactionUpdateMessage carries the new reaction counts of a post or comment
+type ReactionUpdateMessage struct {
+    TargetType string `json:"targetType"`
+    TargetID   int    `json:"targetId"`
+    PostID     int    `json:"postId"`
+    Likes      int    `json:"likes"`
+    Dislikes   int    `json:"dislikes"`
+}
+
+// PollUpdateMessage carries the new tallies of a poll
+type PollUpdateMessage struct {
+    PollID      int         `json:"pollId"`
+    PostID      int         `json:"postId"`
+    TotalVoters int         `json:"totalVoters"`
+    Options     []PollTally `json:"options"`
+}
+
+// PollTally is the vote count of one poll option, with the nicknames of its
+// voters unless the poll is anonymous
+type PollTally struct {
+    OptionID int      `json:"optionId"`
+    Votes    int      `json:"votes"`
+    Voters   []string `json:"voters,omitempty"`
+}
+
+// NotificationMessage pushes a new notification to its user along with how
+// many of their notifications are now unread
+type NotificationMessage struct {
+    Notification models.Notification `json:"notification"`
+    UnreadCount  int                 `json:"unreadCount"`
+}
+
 // OnlineStatusMessage indicates a user's online status has changed
 type OnlineStatusMessage struct {
     UserID int  `json:"userId"`
@@ -95,16 +151,12 @@ func (c *Client) ReadPump() {
             break
         }
         
-        var msg Message
-        if err := json.Unmarshal(message, &msg); err != nil {
-            log.Printf("error unmarshaling message: %v", err)
-            continue
-        }
-        
-        c.Hub.Broadcast <- HubMessage{
-            message: message,
-            client:  c,
-            msgType: msg.Type,
+        // The hub validates the frame and replies with an error frame if it
+        // is rejected. Never wait for a full queue: tell the client to retry.
+        select {
+        case c.Hub.Broadcast <- HubMessage{message: message, client: c}:
+        default:
+            c.Hub.sendError(c, "", &FrameError{Code: ErrCodeBusy, Message: "server is busy, try again"})
         }
     }
 }
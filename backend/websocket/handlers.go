@@ -0,0 +1,277 @@
+// backend/websocket/handlers.go
+package websocket
+
+import (
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "log"
+    "time"
+    
+    "forum/backend/markdown"
+    "forum/backend/models"
+)
+
+// worker processes frames sent by clients. Workers never touch the client
+// maps; every reply goes back through Run as a delivery.
+func (h *Hub) worker() {
+    for {
+        select {
+        case hubMsg := <-h.Broadcast:
+            h.handleFrame(hubMsg)
+        case <-h.quit:
+            return
+        }
+    }
+}
+
+// handleFrame validates a frame and routes it to the handler for its type
+func (h *Hub) handleFrame(hubMsg HubMessage) {
+    msg, ferr := decodeFrame(hubMsg.message)
+    if ferr == nil {
+        switch msg.Type {
+        case "chat_message":
+            ferr = h.handleChatMessage(hubMsg.client, msg)
+        case "typing":
+            ferr = h.handleTypingMessage(hubMsg.client, msg)
+        case "mark_read":
+            ferr = h.handleMarkReadMessage(hubMsg.client, msg)
+        case "new_post":
+            ferr = h.handleNewPostMessage(hubMsg.client, msg)
+        case "new_comment":
+            ferr = h.handleNewCommentMessage(hubMsg.client, msg)
+        }
+    }
+    
+    if ferr != nil {
+        log.Printf("rejected %q frame from user %d: %v", msg.Type, hubMsg.client.UserID, ferr)
+        h.sendError(hubMsg.client, msg.Type, ferr)
+    }
+}
+
+// sendError replies to a client with an error frame describing why its frame was rejected
+func (h *Hub) sendError(client *Client, requestType string, ferr *FrameError) {
+    errMsg := ErrorMessage{
+        Code:        ferr.Code,
+        Message:     ferr.Message,
+        RequestType: requestType,
+    }
+    msgBytes, err := encodeMessage("error", errMsg)
+    if err != nil {
+        log.Printf("error marshaling error frame: %v", err)
+        return
+    }
+    h.deliver(delivery{message: msgBytes, msgType: "error", client: client})
+}
+
+// encodeMessage wraps a payload in a typed message envelope
+func encodeMessage(msgType string, payload interface{}) ([]byte, error) {
+    payloadBytes, err := json.Marshal(payload)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(Message{
+        Type:    msgType,
+        Payload: payloadBytes,
+    })
+}
+// handleChatMessage stores and delivers a chat message sent over WebSocket
+func (h *Hub) handleChatMessage(client *Client, msg Message) *FrameError {
+    var chatMsg ChatMessage
+    if ferr := decodePayload(msg.Payload, &chatMsg); ferr != nil {
+        return ferr
+    }
+    
+    _, err := h.SendChatMessage(client.UserID, chatMsg)
+    var ferr *FrameError
+    if errors.As(err, &ferr) {
+        return ferr
+    }
+    return nil
+}
+
+// SendChatMessage is the single send path for private messages, used by both
+// WebSocket frames and the REST API. It validates the message, stores it and
+// delivers it to every connection of the sender and the receiver. The sender
+// fields, ID and timestamp are set by the server so they cannot be spoofed.
+// Rejected input is reported as a *FrameError.
+func (h *Hub) SendChatMessage(senderID int, chatMsg ChatMessage) (models.Message, error) {
+    if ferr := chatMsg.validate(senderID); ferr != nil {
+        return models.Message{}, ferr
+    }
+    
+    if _, err := models.GetUserByID(h.DB, chatMsg.ReceiverID); err == sql.ErrNoRows {
+        return models.Message{}, invalidf("receiver does not exist")
+    } else if err != nil {
+        log.Printf("error loading receiver %d: %v", chatMsg.ReceiverID, err)
+        return models.Message{}, &FrameError{Code: ErrCodeInternal, Message: "could not send message"}
+    }
+    
+    sender, err := models.GetUserByID(h.DB, senderID)
+    if err != nil {
+        log.Printf("error loading sender %d: %v", senderID, err)
+        return models.Message{}, &FrameError{Code: ErrCodeInternal, Message: "could not send message"}
+    }
+    
+    message := models.Message{
+        SenderID:    senderID,
+        ReceiverID:  chatMsg.ReceiverID,
+        Content:     chatMsg.Content,
+        ContentHTML: markdown.Render(chatMsg.Content),
+        ImageURL:    chatMsg.ImageURL,
+        CreatedAt:   time.Now().UTC(),
+    }
+    
+    messageID, err := models.CreateMessage(h.DB, message)
+    if err != nil {
+        log.Printf("error storing message from user %d: %v", senderID, err)
+        return models.Message{}, &FrameError{Code: ErrCodeInternal, Message: "could not send message"}
+    }
+    message.ID = int(messageID)
+    message.Sender = sender
+    
+    msgBytes, err := encodeMessage("chat_message", ChatMessage{
+        ID:          message.ID,
+        SenderID:    message.SenderID,
+        ReceiverID:  message.ReceiverID,
+        Content:     message.Content,
+        ContentHTML: message.ContentHTML,
+        ImageURL:    message.ImageURL,
+        CreatedAt:   message.CreatedAt,
+        SenderName:  sender.Nickname,
+    })
+    if err != nil {
+        return message, &FrameError{Code: ErrCodeInternal, Message: "could not deliver message"}
+    }
+    
+    // Deliver to the receiver and echo to the sender's connections
+    h.deliver(delivery{message: msgBytes, msgType: "chat_message", userIDs: []int{message.ReceiverID, message.SenderID}})
+    
+    return message, nil
+}
+
+// handleMarkReadMessage marks a conversation read for the client's user
+func (h *Hub) handleMarkReadMessage(client *Client, msg Message) *FrameError {
+    var markRead MarkReadMessage
+    if ferr := decodePayload(msg.Payload, &markRead); ferr != nil {
+        return ferr
+    }
+    
+    _, err := h.MarkConversationRead(client.UserID, markRead)
+    var ferr *FrameError
+    if errors.As(err, &ferr) {
+        return ferr
+    }
+    return nil
+}
+
+// MarkConversationRead records that readerID has read the conversation up to
+// a message, for both WebSocket frames and the REST API. When anything changed,
+// a read_receipt is pushed to the sender and to the reader's other connections.
+// Rejected input is reported as a *FrameError.
+func (h *Hub) MarkConversationRead(readerID int, markRead MarkReadMessage) (ReadReceiptMessage, error) {
+    if ferr := markRead.validate(readerID); ferr != nil {
+        return ReadReceiptMessage{}, ferr
+    }
+    
+    count, readAt, err := models.MarkConversationRead(h.DB, readerID, markRead.UserID, markRead.UpToID)
+    if err != nil {
+        log.Printf("error marking messages read for user %d: %v", readerID, err)
+        return ReadReceiptMessage{}, &FrameError{Code: ErrCodeInternal, Message: "could not mark messages read"}
+    }
+    
+    receipt := ReadReceiptMessage{
+        ReaderID: readerID,
+        SenderID: markRead.UserID,
+        UpToID:   markRead.UpToID,
+        ReadAt:   readAt,
+    }
+    if count > 0 {
+        h.SendToUser("read_receipt", receipt, markRead.UserID, readerID)
+    }
+    
+    return receipt, nil
+}
+
+// handleTypingMessage relays a typing indicator to its receiver
+func (h *Hub) handleTypingMessage(client *Client, msg Message) *FrameError {
+    var typingMsg TypingMessage
+    if ferr := decodePayload(msg.Payload, &typingMsg); ferr != nil {
+        return ferr
+    }
+    if ferr := typingMsg.validate(client.UserID); ferr != nil {
+        return ferr
+    }
+    typingMsg.SenderID = client.UserID
+    
+    msgBytes, err := encodeMessage("typing", typingMsg)
+    if err != nil {
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send typing status"}
+    }
+    
+    // Send typing status to the target user if online
+    h.deliver(delivery{message: msgBytes, msgType: "typing", userIDs: []int{typingMsg.ReceiverID}})
+    return nil
+}
+
+// handleNewPostMessage announces a post the client just published to all
+// other users
+func (h *Hub) handleNewPostMessage(client *Client, msg Message) *FrameError {
+    var postMsg PostMessage
+    if ferr := decodePayload(msg.Payload, &postMsg); ferr != nil {
+        return ferr
+    }
+    if ferr := postMsg.validate(); ferr != nil {
+        return ferr
+    }
+    
+    post, err := models.GetPostByID(h.DB, postMsg.PostID, client.UserID)
+    if err == models.ErrPostNotFound || (err == nil && (post.Status != models.PostPublished || post.UserID != client.UserID)) {
+        return invalidf("postId is not your published post")
+    }
+    if err != nil {
+        log.Printf("error loading post %d: %v", postMsg.PostID, err)
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send notification"}
+    }
+    
+    msgBytes, err := encodeMessage("new_post", postMsg)
+    if err != nil {
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send notification"}
+    }
+    h.deliver(delivery{message: msgBytes, msgType: "new_post", all: true, exclude: client})
+    return nil
+}
+
+// handleNewCommentMessage announces a comment the client just posted to the
+// other users following its post
+func (h *Hub) handleNewCommentMessage(client *Client, msg Message) *FrameError {
+    var commentMsg CommentMessage
+    if ferr := decodePayload(msg.Payload, &commentMsg); ferr != nil {
+        return ferr
+    }
+    if ferr := commentMsg.validate(); ferr != nil {
+        return ferr
+    }
+    
+    comment, err := models.GetCommentByID(h.DB, commentMsg.CommentID, client.UserID)
+    if err == models.ErrCommentNotFound || (err == nil && (comment.PostID != commentMsg.PostID || comment.UserID != client.UserID)) {
+        return invalidf("commentId is not your comment on this post")
+    }
+    if err != nil {
+        log.Printf("error loading comment %d: %v", commentMsg.CommentID, err)
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send notification"}
+    }
+    
+    followers, err := models.GetPostFollowers(h.DB, commentMsg.PostID)
+    if err != nil {
+        log.Printf("error loading followers of post %d: %v", commentMsg.PostID, err)
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send notification"}
+    }
+    
+    msgBytes, err := encodeMessage("new_comment", commentMsg)
+    if err != nil {
+        return &FrameError{Code: ErrCodeInternal, Message: "could not send notification"}
+    }
+    h.deliver(delivery{message: msgBytes, msgType: "new_comment", userIDs: followers, exclude: client})
+    return nil
+}
@@ -3,26 +3,55 @@ package websocket
 
 import (
     "database/sql"
-    "encoding/json"
     "log"
+    "strconv"
+    "time"
 )
 
-// HubMessage combines a message with its sender and type
+// HubMessage combines a raw incoming frame with the client that sent it
 type HubMessage struct {
     message []byte
     client  *Client
+}
+
+// delivery is an outgoing frame waiting to be handed to client connections.
+// Frames reach the hub's clients only through Run, which owns the client maps.
+type delivery struct {
+    message []byte
+
+    // Frame type, which decides how the frame is treated when a client is behind
     msgType string
+
+    // What a frame carrying the latest state describes, so a client that is
+    // behind only gets the newest frame for each key
+    key string
+
+    // Deliver to every connection of these users
+    userIDs []int
+
+    // Deliver to this single connection
+    client *Client
+
+    // Deliver to every connection
+    all bool
+
+    // Never deliver to this connection
+    exclude *Client
 }
 
-// Hub maintains the set of active clients and broadcasts messages
+// Hub maintains the set of active clients and broadcasts messages.
+//
+// Run is the only goroutine that reads or writes Clients and UserClients and
+// the only one that closes a client's Send channel. Everything else (the
+// frame workers, HTTP handlers) talks to it over channels.
 type Hub struct {
     // Registered clients
     Clients map[*Client]bool
 
-    // User ID to client mapping for direct messaging
-    UserClients map[int]*Client
+    // User ID to the set of that user's connections for direct messaging
+    UserClients map[int]map[*Client]bool
 
-    // Inbound messages from clients
+    // Inbound messages from clients, processed by the worker goroutines
     Broadcast chan HubMessage
 
     // Register requests from clients
@@ -31,175 +60,282 @@ type Hub struct {
     // Unregister requests from clients
     Unregister chan *Client
     
+    // Session IDs whose connections must be closed
+    disconnect chan string
+    
+    // Outgoing frames to be handed to clients by Run. Unbuffered, so a frame
+    // is dispatched before anything its sender does next reaches Run.
+    outbound chan delivery
+    
+    // Functions run inside Run to read hub state safely
+    inspect chan func()
+    
+    // Closed to stop Run and the workers
+    quit chan struct{}
+    
+    // Clients with frames held back because their Send buffer was full
+    slow map[*Client]bool
+    
     // Database connection
     DB *sql.DB
-
-	// Work queue for handling messages
-    messageQueue chan HubMessage
     
-    // Number of worker goroutines
-    workerCount int
+    // Buffering and slow client settings
+    Config Config
 }
 
-// NewHub creates a new hub
+// NewHub creates a new hub with the default configuration
 func NewHub(db *sql.DB) *Hub {
+    return NewHubWithConfig(db, DefaultConfig())
+}
+
+// NewHubWithConfig creates a new hub with the given buffering and slow client settings
+func NewHubWithConfig(db *sql.DB, config Config) *Hub {
     return &Hub{
-        Broadcast:    make(chan HubMessage),
-        Register:     make(chan *Client),
-        Unregister:   make(chan *Client),
-        Clients:      make(map[*Client]bool),
-        UserClients:  make(map[int]*Client),
-        DB:           db,
-        messageQueue: make(chan HubMessage, 100), // Buffered channel for message queue
-        workerCount:  4, // Number of worker goroutines
+        Broadcast:   make(chan HubMessage, config.IngressQueueSize),
+        Register:    make(chan *Client),
+        Unregister:  make(chan *Client),
+        disconnect:  make(chan string),
+        outbound:    make(chan delivery),
+        inspect:     make(chan func()),
+        quit:        make(chan struct{}),
+        Clients:     make(map[*Client]bool),
+        UserClients: make(map[int]map[*Client]bool),
+        slow:        make(map[*Client]bool),
+        DB:          db,
+        Config:      config,
     }
 }
 
-// Run starts the hub
+// Run starts the hub and blocks until Stop is called
 func (h *Hub) Run() {
     // Start worker goroutines
-    for i := 0; i < h.workerCount; i++ {
+    for i := 0; i < h.Config.WorkerCount; i++ {
         go h.worker()
     }
     
+    flushTicker := time.NewTicker(h.Config.FlushInterval)
+    defer flushTicker.Stop()
+    
     for {
         select {
         case client := <-h.Register:
-            h.Clients[client] = true
-            h.UserClients[client.UserID] = client
-            
-            // Broadcast online status
-            onlineMsg := OnlineStatusMessage{
-                UserID: client.UserID,
-                Online: true,
-            }
-            payload, _ := json.Marshal(onlineMsg)
-            msg := Message{
-                Type:    "online_status",
-                Payload: payload,
-            }
-            msgBytes, _ := json.Marshal(msg)
-            h.broadcastToAll(msgBytes, nil)
+            h.addClient(client)
             
         case client := <-h.Unregister:
-            if _, ok := h.Clients[client]; ok {
-                delete(h.Clients, client)
-                delete(h.UserClients, client.UserID)
-                close(client.Send)
-                
-                // Broadcast offline status
-                offlineMsg := OnlineStatusMessage{
-                    UserID: client.UserID,
-                    Online: false,
-                }
-                payload, _ := json.Marshal(offlineMsg)
-                msg := Message{
-                    Type:    "online_status",
-                    Payload: payload,
+            h.removeClient(client)
+            
+        case sessionID := <-h.disconnect:
+            for client := range h.Clients {
+                if client.SessionID == sessionID {
+                    h.removeClient(client)
                 }
-                msgBytes, _ := json.Marshal(msg)
-                h.broadcastToAll(msgBytes, nil)
             }
             
-        case hubMsg := <-h.Broadcast:
-            // Enqueue message for processing by worker goroutines
-            h.messageQueue <- hubMsg
+        case d := <-h.outbound:
+            h.dispatch(d)
+            
+        case fn := <-h.inspect:
+            fn()
+            
+        case now := <-flushTicker.C:
+            h.flushSlowClients(now)
+            
+        case <-h.quit:
+            for client := range h.Clients {
+                h.detachClient(client)
+            }
+            return
         }
     }
 }
 
-// worker is a goroutine that processes messages from the queue
-func (h *Hub) worker() {
-    for hubMsg := range h.messageQueue {
-        switch hubMsg.msgType {
-        case "chat_message":
-            h.handleChatMessage(hubMsg)
-        case "typing":
-            h.handleTypingMessage(hubMsg)
-        case "new_post":
-            h.handleNewPostMessage(hubMsg)
-        case "new_comment":
-            h.handleNewCommentMessage(hubMsg)
-        }
+// Stop shuts the hub down and closes every client connection
+func (h *Hub) Stop() {
+    close(h.quit)
+}
+
+// DisconnectSession closes every connection opened with the given session
+func (h *Hub) DisconnectSession(sessionID string) {
+    select {
+    case h.disconnect <- sessionID:
+    case <-h.quit:
     }
 }
 
-// broadcastToAll sends a message to all connected clients except the sender
-func (h *Hub) broadcastToAll(message []byte, sender *Client) {
-    for client := range h.Clients {
-        if client != sender {
-            select {
-            case client.Send <- message:
-            default:
-                close(client.Send)
-                delete(h.Clients, client)
-                delete(h.UserClients, client.UserID)
-            }
-        }
+// IsOnline reports whether a user has at least one open connection
+func (h *Hub) IsOnline(userID int) bool {
+    var online bool
+    h.do(func() {
+        online = len(h.UserClients[userID]) > 0
+    })
+    return online
+}
+
+// ClientCount returns the number of open connections
+func (h *Hub) ClientCount() int {
+    var count int
+    h.do(func() {
+        count = len(h.Clients)
+    })
+    return count
+}
+
+// do runs fn on the Run goroutine and waits for it to finish
+func (h *Hub) do(fn func()) {
+    done := make(chan struct{})
+    select {
+    case h.inspect <- func() {
+        fn()
+        close(done)
+    }:
+        <-done
+    case <-h.quit:
     }
 }
 
-// handleChatMessage processes a chat message
-func (h *Hub) handleChatMessage(hubMsg HubMessage) {
-    var msg Message
-    if err := json.Unmarshal(hubMsg.message, &msg); err != nil {
-        log.Printf("error unmarshaling message: %v", err)
+// SendToUser delivers a frame to every connection of the given users
+func (h *Hub) SendToUser(msgType string, payload interface{}, userIDs ...int) {
+    msgBytes, err := encodeMessage(msgType, payload)
+    if err != nil {
+        log.Printf("error marshaling %s frame: %v", msgType, err)
         return
     }
-    
-    var chatMsg ChatMessage
-    if err := json.Unmarshal(msg.Payload, &chatMsg); err != nil {
-        log.Printf("error unmarshaling chat message: %v", err)
+    h.deliver(delivery{message: msgBytes, msgType: msgType, userIDs: userIDs})
+}
+
+// BroadcastEvent delivers a frame to every connected client
+func (h *Hub) BroadcastEvent(msgType string, payload interface{}) {
+    msgBytes, err := encodeMessage(msgType, payload)
+    if err != nil {
+        log.Printf("error marshaling %s frame: %v", msgType, err)
         return
     }
+    h.deliver(delivery{message: msgBytes, msgType: msgType, all: true})
+}
+
+// BroadcastState delivers a frame describing the current state of something,
+// such as a post's reaction counts, to every connected client. Clients that
+// are behind only receive the newest frame for each key.
+func (h *Hub) BroadcastState(msgType string, key string, payload interface{}) {
+    msgBytes, err := encodeMessage(msgType, payload)
+    if err != nil {
+        log.Printf("error marshaling %s frame: %v", msgType, err)
+        return
+    }
+    h.deliver(delivery{message: msgBytes, msgType: msgType, key: msgType + ":" + key, all: true})
+}
+
+// deliver hands an outgoing frame to Run
+func (h *Hub) deliver(d delivery) {
+    select {
+    case h.outbound <- d:
+    case <-h.quit:
+    }
+}
+
+// addClient registers a client. The user is broadcast as online when this is
+// their first connection.
+func (h *Hub) addClient(client *Client) {
+    h.Clients[client] = true
+    if h.UserClients[client.UserID] == nil {
+        h.UserClients[client.UserID] = make(map[*Client]bool)
+    }
+    h.UserClients[client.UserID][client] = true
     
-    // Send message to the target user if online
-    if receiver, ok := h.UserClients[chatMsg.ReceiverID]; ok {
-        select {
-        case receiver.Send <- hubMsg.message:
-        default:
-            close(receiver.Send)
-            delete(h.Clients, receiver)
-            delete(h.UserClients, chatMsg.ReceiverID)
-        }
+    if len(h.UserClients[client.UserID]) == 1 {
+        h.broadcastStatus(client.UserID, true)
     }
 }
 
-// handleTypingMessage processes a typing indicator message
-func (h *Hub) handleTypingMessage(hubMsg HubMessage) {
-    var msg Message
-    if err := json.Unmarshal(hubMsg.message, &msg); err != nil {
-        log.Printf("error unmarshaling message: %v", err)
+// removeClient unregisters a client and closes its send channel. The user is
+// broadcast as offline once their last connection is gone. Removing a client
+// that is already gone does nothing.
+func (h *Hub) removeClient(client *Client) {
+    if !h.detachClient(client) {
         return
     }
+    if len(h.UserClients[client.UserID]) == 0 {
+        h.broadcastStatus(client.UserID, false)
+    }
+}
+
+// detachClient removes a client from the hub's maps and closes its send
+// channel. It reports whether the client was still registered, so the
+// channel is closed exactly once.
+func (h *Hub) detachClient(client *Client) bool {
+    if _, ok := h.Clients[client]; !ok {
+        return false
+    }
     
-    var typingMsg TypingMessage
-    if err := json.Unmarshal(msg.Payload, &typingMsg); err != nil {
-        log.Printf("error unmarshaling typing message: %v", err)
+    delete(h.Clients, client)
+    delete(h.slow, client)
+    client.pending = nil
+    if userClients := h.UserClients[client.UserID]; userClients != nil {
+        delete(userClients, client)
+        if len(userClients) == 0 {
+            delete(h.UserClients, client.UserID)
+        }
+    }
+    close(client.Send)
+    return true
+}
+
+// broadcastStatus tells every client that a user came online or went offline
+func (h *Hub) broadcastStatus(userID int, online bool) {
+    msgBytes, err := encodeMessage("online_status", OnlineStatusMessage{
+        UserID: userID,
+        Online: online,
+    })
+    if err != nil {
+        log.Printf("error marshaling online status: %v", err)
         return
     }
+    key := "online_status:" + strconv.Itoa(userID)
+    h.dispatch(delivery{message: msgBytes, msgType: "online_status", key: key, all: true})
+}
+
+// dispatch hands a frame to the connections it is addressed to, applying the
+// slow client policy to any whose buffers are full. Evicted users are
+// announced offline afterwards so eviction never happens while the maps are
+// being iterated.
+func (h *Hub) dispatch(d delivery) {
+    frame := queuedFrame{message: d.message, class: classOf(d.msgType), key: d.key}
+    now := time.Now()
     
-    // Update client typing state
-    hubMsg.client.IsTyping = typingMsg.IsTyping
+    var behind []*Client
+    send := func(client *Client) {
+        if client == d.exclude {
+            return
+        }
+        if !h.enqueue(client, frame, now) {
+            behind = append(behind, client)
+        }
+    }
     
-    // Send typing status to the target user if online
-    if receiver, ok := h.UserClients[typingMsg.ReceiverID]; ok {
-        select {
-        case receiver.Send <- hubMsg.message:
-        default:
-            close(receiver.Send)
-            delete(h.Clients, receiver)
-            delete(h.UserClients, typingMsg.ReceiverID)
+    switch {
+    case d.all:
+        for client := range h.Clients {
+            send(client)
+        }
+    case d.client != nil:
+        if h.Clients[d.client] {
+            send(d.client)
+        }
+    default:
+        for _, userID := range d.userIDs {
+            for client := range h.UserClients[userID] {
+                send(client)
+            }
         }
     }
+    
+    for _, client := range behind {
+        h.evict(client, "too many frames held")
+    }
 }
 
-// handleNewPostMessage broadcasts a new post notification to all users
-func (h *Hub) handleNewPostMessage(hubMsg HubMessage) {
-    h.broadcastToAll(hubMsg.message, hubMsg.client)
+// evict disconnects a client that can't keep up
+func (h *Hub) evict(client *Client, reason string) {
+    log.Printf("evicting slow client for user %d: %s", client.UserID, reason)
+    h.removeClient(client)
 }
-
-// handleNewCommentMessage broadcasts a new comment notification to all users
-func (h *Hub) handleNewCommentMessage(hubMsg HubMessage) {
-    h.broadcastToAll(hubMsg.message, hubMsg.client)
-}
\ No newline at end of file
@@ -0,0 +1,454 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestHub starts a hub without a database; none of these tests touch it
+func newTestHub(t *testing.T) *Hub {
+	t.Helper()
+	return newTestHubWithConfig(t, DefaultConfig())
+}
+
+func newTestHubWithConfig(t *testing.T, config Config) *Hub {
+	t.Helper()
+	hub := NewHubWithConfig(nil, config)
+	go hub.Run()
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+// newTestClient creates a client with no network connection; frames sent to
+// it pile up in its Send buffer
+func newTestClient(hub *Hub, userID int, buffer int) *Client {
+	return &Client{
+		Hub:       hub,
+		Send:      make(chan []byte, buffer),
+		UserID:    userID,
+		SessionID: fmt.Sprintf("session-%d", userID),
+	}
+}
+
+// drain returns every frame currently buffered for a client, decoded
+func drain(t *testing.T, client *Client) []Message {
+	t.Helper()
+	var frames []Message
+	for {
+		select {
+		case raw, ok := <-client.Send:
+			if !ok {
+				return frames
+			}
+			var msg Message
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatalf("undecodable frame %q: %v", raw, err)
+			}
+			frames = append(frames, msg)
+		default:
+			return frames
+		}
+	}
+}
+
+// statusFrames returns the online_status payloads among frames for a user
+func statusFrames(t *testing.T, frames []Message, userID int) []bool {
+	t.Helper()
+	var statuses []bool
+	for _, frame := range frames {
+		if frame.Type != "online_status" {
+			continue
+		}
+		var status OnlineStatusMessage
+		if err := json.Unmarshal(frame.Payload, &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.UserID == userID {
+			statuses = append(statuses, status.Online)
+		}
+	}
+	return statuses
+}
+
+// isClosed reports whether a client's Send channel has been closed, draining it
+func isClosed(client *Client) bool {
+	for {
+		select {
+		case _, ok := <-client.Send:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestOnlineStatusOnFirstConnectAndLastDisconnect(t *testing.T) {
+	hub := newTestHub(t)
+	observer := newTestClient(hub, 1, 64)
+	hub.Register <- observer
+
+	tab1 := newTestClient(hub, 2, 64)
+	tab2 := newTestClient(hub, 2, 64)
+	hub.Register <- tab1
+	hub.Register <- tab2
+	hub.Unregister <- tab1
+
+	if !hub.IsOnline(2) {
+		t.Fatal("user 2 should still be online with one tab open")
+	}
+
+	hub.Unregister <- tab2
+	if hub.IsOnline(2) {
+		t.Fatal("user 2 should be offline after closing every tab")
+	}
+
+	got := statusFrames(t, drain(t, observer), 2)
+	if len(got) != 2 || got[0] != true || got[1] != false {
+		t.Fatalf("observer saw statuses %v, want [true false]", got)
+	}
+}
+
+func TestDirectDeliveryReachesEveryConnection(t *testing.T) {
+	hub := newTestHub(t)
+	tab1 := newTestClient(hub, 2, 64)
+	tab2 := newTestClient(hub, 2, 64)
+	other := newTestClient(hub, 3, 64)
+	hub.Register <- tab1
+	hub.Register <- tab2
+	hub.Register <- other
+
+	hub.SendToUser("typing", TypingMessage{SenderID: 3, ReceiverID: 2, IsTyping: true}, 2)
+	hub.ClientCount() // wait for the delivery to be dispatched
+
+	for _, client := range []*Client{tab1, tab2} {
+		var typing int
+		for _, frame := range drain(t, client) {
+			if frame.Type == "typing" {
+				typing++
+			}
+		}
+		if typing != 1 {
+			t.Fatalf("tab got %d typing frames, want 1", typing)
+		}
+	}
+	for _, frame := range drain(t, other) {
+		if frame.Type == "typing" {
+			t.Fatal("typing frame delivered to the wrong user")
+		}
+	}
+}
+
+func TestUnregisterIsIdempotent(t *testing.T) {
+	hub := newTestHub(t)
+	client := newTestClient(hub, 1, 64)
+	hub.Register <- client
+
+	// Every path that removes a client may race to remove the same one
+	hub.DisconnectSession(client.SessionID)
+	hub.Unregister <- client
+	hub.Unregister <- client
+
+	if hub.ClientCount() != 0 {
+		t.Fatal("client still registered")
+	}
+	if !isClosed(client) {
+		t.Fatal("send channel not closed")
+	}
+}
+
+func TestSlowClientIsEvictedOnce(t *testing.T) {
+	config := DefaultConfig()
+	config.PendingLimit = 4
+	hub := newTestHubWithConfig(t, config)
+	slow := newTestClient(hub, 1, 1)
+	fast := newTestClient(hub, 2, 256)
+	hub.Register <- slow
+	hub.Register <- fast
+
+	for i := 0; i < 10; i++ {
+		hub.BroadcastEvent("new_post", PostMessage{PostID: i + 1})
+	}
+
+	if hub.IsOnline(1) {
+		t.Fatal("slow client should have been evicted")
+	}
+	if !hub.IsOnline(2) {
+		t.Fatal("fast client should still be connected")
+	}
+
+	// The reader noticing the closed channel unregisters again; that must not panic
+	hub.Unregister <- slow
+	if !isClosed(slow) {
+		t.Fatal("slow client's send channel not closed")
+	}
+
+	// fast registered after slow, so it only ever hears that slow left
+	got := statusFrames(t, drain(t, fast), 1)
+	if len(got) != 1 || got[0] != false {
+		t.Fatalf("fast client saw statuses %v for the slow user, want [false]", got)
+	}
+}
+
+func TestSlowClientHeldFramesArriveInOrder(t *testing.T) {
+	hub := newTestHub(t)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+	drain(t, slow)
+
+	for i := 1; i <= 5; i++ {
+		hub.BroadcastEvent("new_post", PostMessage{PostID: i})
+	}
+
+	// Read one frame at a time, as a slow writer would; the hub refills
+	// Send from its held frames on the next flush
+	var got []int
+	deadline := time.After(2 * time.Second)
+	for len(got) < 5 {
+		select {
+		case raw := <-slow.Send:
+			var msg Message
+			var post PostMessage
+			json.Unmarshal(raw, &msg)
+			json.Unmarshal(msg.Payload, &post)
+			got = append(got, post.PostID)
+		case <-deadline:
+			t.Fatalf("only received posts %v", got)
+		}
+	}
+	for i, postID := range got {
+		if postID != i+1 {
+			t.Fatalf("posts arrived as %v, want 1..5 in order", got)
+		}
+	}
+	if !hub.IsOnline(1) {
+		t.Fatal("client that caught up should stay connected")
+	}
+}
+
+func TestTypingFramesDroppedBeforeCritical(t *testing.T) {
+	config := DefaultConfig()
+	config.PendingLimit = 3
+	hub := newTestHubWithConfig(t, config)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+
+	// Send is full with the client's own online status; everything else is held
+	hub.SendToUser("typing", TypingMessage{SenderID: 2, ReceiverID: 1, IsTyping: true}, 1)
+	hub.SendToUser("typing", TypingMessage{SenderID: 2, ReceiverID: 1, IsTyping: false}, 1)
+	hub.SendToUser("chat_message", ChatMessage{ID: 1, SenderID: 2, ReceiverID: 1}, 1)
+	hub.SendToUser("chat_message", ChatMessage{ID: 2, SenderID: 2, ReceiverID: 1}, 1)
+	hub.SendToUser("typing", TypingMessage{SenderID: 2, ReceiverID: 1, IsTyping: true}, 1)
+
+	var types []string
+	hub.do(func() {
+		if !hub.Clients[slow] {
+			t.Error("client evicted although only typing frames overflowed")
+		}
+		for _, held := range slow.pending {
+			var msg Message
+			json.Unmarshal(held.message, &msg)
+			types = append(types, msg.Type)
+		}
+	})
+
+	want := []string{"chat_message", "chat_message", "typing"}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("held frames %v, want %v", types, want)
+	}
+}
+
+func TestPresenceUpdatesCoalesce(t *testing.T) {
+	hub := newTestHub(t)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+
+	// User 2 flaps online and offline while the observer isn't reading
+	for i := 0; i < 5; i++ {
+		tab := newTestClient(hub, 2, 64)
+		hub.Register <- tab
+		hub.Unregister <- tab
+	}
+
+	var held []bool
+	hub.do(func() {
+		for _, frame := range slow.pending {
+			var msg Message
+			var status OnlineStatusMessage
+			json.Unmarshal(frame.message, &msg)
+			json.Unmarshal(msg.Payload, &status)
+			held = append(held, status.Online)
+		}
+	})
+
+	if len(held) != 1 || held[0] != false {
+		t.Fatalf("held presence frames %v, want only the latest [false]", held)
+	}
+}
+
+func TestReactionUpdatesCoalescePerTarget(t *testing.T) {
+	hub := newTestHub(t)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+
+	// Send is full with the client's own online status; counts pile up behind it
+	for likes := 1; likes <= 3; likes++ {
+		hub.BroadcastState("reaction_update", "post:7", ReactionUpdateMessage{TargetType: "post", TargetID: 7, Likes: likes})
+		hub.BroadcastState("reaction_update", "comment:7", ReactionUpdateMessage{TargetType: "comment", TargetID: 7, Likes: likes * 10})
+	}
+
+	var held []string
+	hub.do(func() {
+		for _, frame := range slow.pending {
+			var msg Message
+			var update ReactionUpdateMessage
+			json.Unmarshal(frame.message, &msg)
+			json.Unmarshal(msg.Payload, &update)
+			held = append(held, fmt.Sprintf("%s:%d", update.TargetType, update.Likes))
+		}
+	})
+
+	want := []string{"post:3", "comment:30"}
+	if fmt.Sprint(held) != fmt.Sprint(want) {
+		t.Fatalf("held reaction frames %v, want only the latest per target %v", held, want)
+	}
+}
+
+func TestUncoalescedStateFrameOnFullQueueEvicts(t *testing.T) {
+	config := DefaultConfig()
+	config.PendingLimit = 2
+	hub := newTestHubWithConfig(t, config)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+
+	// Send is full with the client's own online status; the held frames
+	// leave no room and nothing a new reaction count could replace
+	hub.SendToUser("chat_message", ChatMessage{ID: 1, SenderID: 2, ReceiverID: 1}, 1)
+	hub.SendToUser("chat_message", ChatMessage{ID: 2, SenderID: 2, ReceiverID: 1}, 1)
+	if !hub.IsOnline(1) {
+		t.Fatal("client evicted before its queue overflowed")
+	}
+
+	hub.BroadcastState("reaction_update", "post:7", ReactionUpdateMessage{TargetType: "post", TargetID: 7, Likes: 1})
+	if hub.IsOnline(1) {
+		t.Fatal("client kept although a reaction count could not be queued")
+	}
+}
+
+func TestSlowClientEvictedAfterGracePeriod(t *testing.T) {
+	config := DefaultConfig()
+	config.SlowClientGrace = 50 * time.Millisecond
+	config.FlushInterval = 10 * time.Millisecond
+	hub := newTestHubWithConfig(t, config)
+	slow := newTestClient(hub, 1, 1)
+	hub.Register <- slow
+
+	hub.BroadcastEvent("new_post", PostMessage{PostID: 1})
+	if !hub.IsOnline(1) {
+		t.Fatal("client evicted before its grace period")
+	}
+
+	deadline := time.After(2 * time.Second)
+	for hub.IsOnline(1) {
+		select {
+		case <-deadline:
+			t.Fatal("client never evicted")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestConcurrentRegisterBroadcastUnregister(t *testing.T) {
+	hub := newTestHub(t)
+	const users = 50
+	const tabs = 4
+
+	var wg sync.WaitGroup
+	for u := 1; u <= users; u++ {
+		for tab := 0; tab < tabs; tab++ {
+			wg.Add(1)
+			go func(userID int) {
+				defer wg.Done()
+				client := newTestClient(hub, userID, 1024)
+				hub.Register <- client
+				hub.SendToUser("typing", TypingMessage{SenderID: userID, ReceiverID: userID%users + 1}, userID%users+1)
+				hub.BroadcastEvent("new_post", PostMessage{PostID: userID})
+				hub.Unregister <- client
+
+				// Consume everything until the hub closes the channel
+				deadline := time.After(5 * time.Second)
+				for {
+					select {
+					case _, ok := <-client.Send:
+						if !ok {
+							return
+						}
+					case <-deadline:
+						t.Errorf("send channel for user %d never closed", userID)
+						return
+					}
+				}
+			}(u)
+		}
+	}
+	wg.Wait()
+
+	if count := hub.ClientCount(); count != 0 {
+		t.Fatalf("%d clients left registered", count)
+	}
+	for u := 1; u <= users; u++ {
+		if hub.IsOnline(u) {
+			t.Fatalf("user %d still online", u)
+		}
+	}
+}
+
+func TestRejectedFrameGetsErrorReply(t *testing.T) {
+	hub := newTestHub(t)
+	client := newTestClient(hub, 1, 64)
+	hub.Register <- client
+
+	hub.Broadcast <- HubMessage{message: []byte(`{"type":"typing","payload":{"receiverId":1}}`), client: client}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case raw := <-client.Send:
+			var msg Message
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Type != "error" {
+				continue
+			}
+			var errMsg ErrorMessage
+			if err := json.Unmarshal(msg.Payload, &errMsg); err != nil {
+				t.Fatal(err)
+			}
+			if errMsg.Code != ErrCodeInvalid || errMsg.RequestType != "typing" {
+				t.Fatalf("unexpected error frame %+v", errMsg)
+			}
+			return
+		case <-deadline:
+			t.Fatal("no error frame received")
+		}
+	}
+}
+
+func TestChatContentLimitCountsCharacters(t *testing.T) {
+	// 700 characters of three bytes each is over the limit in bytes only
+	msg := ChatMessage{ReceiverID: 2, Content: strings.Repeat("日", 700)}
+	if ferr := msg.validate(1); ferr != nil {
+		t.Fatalf("700 characters rejected: %v", ferr)
+	}
+
+	msg.Content = strings.Repeat("a", maxChatContentLength+1)
+	if ferr := msg.validate(1); ferr == nil || ferr.Code != ErrCodeInvalid {
+		t.Fatalf("%d characters accepted", maxChatContentLength+1)
+	}
+}
@@ -0,0 +1,154 @@
+// backend/websocket/policy.go
+package websocket
+
+import (
+	"time"
+)
+
+// Config controls how the hub buffers frames and treats clients that read
+// too slowly
+type Config struct {
+	// Frames buffered in a client's Send channel for its writer
+	SendBufferSize int
+
+	// Frames held by the hub for a client whose Send buffer is full
+	PendingLimit int
+
+	// How long a client may stay behind before it is disconnected
+	SlowClientGrace time.Duration
+
+	// How often the hub retries delivering held frames
+	FlushInterval time.Duration
+
+	// Incoming frames waiting for a worker; frames beyond this are rejected
+	IngressQueueSize int
+
+	// Number of goroutines processing incoming frames
+	WorkerCount int
+}
+
+// DefaultConfig returns the settings used by NewHub
+func DefaultConfig() Config {
+	return Config{
+		SendBufferSize:   256,
+		PendingLimit:     64,
+		SlowClientGrace:  10 * time.Second,
+		FlushInterval:    250 * time.Millisecond,
+		IngressQueueSize: 100,
+		WorkerCount:      4,
+	}
+}
+
+// frameClass decides what happens to a frame when its client can't keep up
+type frameClass int
+
+const (
+	// Held until delivered; a client that can't catch up is disconnected
+	frameCritical frameClass = iota
+
+	// Dropped, oldest first, to make room when the client is behind
+	frameDroppable
+
+	// Replaced by any newer frame with the same key; only the latest state
+	// matters, as with presence and reaction counts
+	frameLatest
+)
+
+// classOf returns the backpressure class for a frame type
+func classOf(msgType string) frameClass {
+	switch msgType {
+	case "typing":
+		return frameDroppable
+	case "online_status", "reaction_update":
+		return frameLatest
+	default:
+		return frameCritical
+	}
+}
+
+// queuedFrame is a frame held by the hub for a slow client
+type queuedFrame struct {
+	message []byte
+	class   frameClass
+
+	// What the frame describes, for coalescing frameLatest frames
+	key string
+}
+
+// enqueue delivers a frame to a client, holding it back if the client's Send
+// buffer is full. It reports false when the client has fallen too far behind
+// and must be disconnected. Only Run calls this.
+func (h *Hub) enqueue(client *Client, frame queuedFrame, now time.Time) bool {
+	// Keep frames in order: anything already held goes out first
+	if len(client.pending) > 0 {
+		h.flushClient(client)
+	}
+	if len(client.pending) == 0 {
+		select {
+		case client.Send <- frame.message:
+			return true
+		default:
+			client.slowSince = now
+			h.slow[client] = true
+		}
+	}
+
+	if frame.class == frameLatest {
+		for i, held := range client.pending {
+			if held.class == frameLatest && held.key == frame.key {
+				client.pending[i] = frame
+				return true
+			}
+		}
+	}
+
+	// When the queue is full, typing indicators make room first. Without
+	// any to drop, a typing frame is discarded, but any other one means
+	// the client can no longer be kept up to date: a latest-state frame
+	// that found nothing to replace is the only copy of that state.
+	if len(client.pending) >= h.Config.PendingLimit && !client.dropOldestDroppable() {
+		return frame.class == frameDroppable
+	}
+
+	client.pending = append(client.pending, frame)
+	return true
+}
+
+// flushClient moves held frames into the client's Send buffer while it has room
+func (h *Hub) flushClient(client *Client) {
+	for len(client.pending) > 0 {
+		select {
+		case client.Send <- client.pending[0].message:
+			client.pending[0] = queuedFrame{}
+			client.pending = client.pending[1:]
+		default:
+			return
+		}
+	}
+	client.pending = nil
+	client.slowSince = time.Time{}
+	delete(h.slow, client)
+}
+
+// flushSlowClients retries held frames and disconnects clients that have been
+// behind for longer than the grace period
+func (h *Hub) flushSlowClients(now time.Time) {
+	for client := range h.slow {
+		h.flushClient(client)
+		if len(client.pending) > 0 && now.Sub(client.slowSince) > h.Config.SlowClientGrace {
+			h.evict(client, "still behind after grace period")
+		}
+	}
+}
+
+// dropOldestDroppable discards the oldest held frame that may be dropped and
+// reports whether one was found
+func (c *Client) dropOldestDroppable() bool {
+	for i, held := range c.pending {
+		if held.class == frameDroppable {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// backend/websocket/validate.go
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Maximum length of a chat message's text
+	maxChatContentLength = 2000
+
+	// Maximum length of a chat message's image URL
+	maxImageURLLength = 256
+
+	// Uploaded chat images are served from here
+	imageURLPrefix = "/uploads/images/"
+)
+
+// Error codes sent back to clients in error frames
+const (
+	ErrCodeMalformed   = "malformed_frame"
+	ErrCodeUnknownType = "unknown_type"
+	ErrCodeInvalid     = "invalid_payload"
+	ErrCodeInternal    = "internal_error"
+	ErrCodeBusy        = "server_busy"
+)
+
+// ErrorMessage is sent to a client whose frame was rejected
+type ErrorMessage struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	RequestType string `json:"requestType,omitempty"`
+}
+
+// FrameError describes why an incoming frame was rejected
+type FrameError struct {
+	Code    string
+	Message string
+}
+
+func (e *FrameError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func invalidf(format string, args ...interface{}) *FrameError {
+	return &FrameError{Code: ErrCodeInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+// decodeFrame parses the envelope of an incoming frame and checks its type is
+// one clients are allowed to send
+func decodeFrame(raw []byte) (Message, *FrameError) {
+	var msg Message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return msg, &FrameError{Code: ErrCodeMalformed, Message: "frame is not valid JSON"}
+	}
+
+	switch msg.Type {
+	case "chat_message", "typing", "mark_read", "new_post", "new_comment":
+	case "":
+		return msg, &FrameError{Code: ErrCodeMalformed, Message: "frame type is required"}
+	default:
+		return msg, &FrameError{Code: ErrCodeUnknownType, Message: fmt.Sprintf("unknown frame type %q", msg.Type)}
+	}
+
+	if len(msg.Payload) == 0 || string(msg.Payload) == "null" {
+		return msg, &FrameError{Code: ErrCodeMalformed, Message: "payload is required"}
+	}
+
+	return msg, nil
+}
+
+// decodePayload unmarshals a frame payload into its typed message
+func decodePayload(payload json.RawMessage, v interface{}) *FrameError {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return invalidf("payload does not match schema: %v", err)
+	}
+	return nil
+}
+
+// validate checks a chat message sent by senderID
+func (m *ChatMessage) validate(senderID int) *FrameError {
+	if m.ReceiverID <= 0 {
+		return invalidf("receiverId is required")
+	}
+	if m.ReceiverID == senderID {
+		return invalidf("cannot send a message to yourself")
+	}
+	if strings.TrimSpace(m.Content) == "" && m.ImageURL == "" {
+		return invalidf("content or imageUrl is required")
+	}
+	if utf8.RuneCountInString(m.Content) > maxChatContentLength {
+		return invalidf("content exceeds %d characters", maxChatContentLength)
+	}
+	if m.ImageURL != "" {
+		if len(m.ImageURL) > maxImageURLLength || !strings.HasPrefix(m.ImageURL, imageURLPrefix) ||
+			strings.Contains(m.ImageURL, "..") {
+			return invalidf("imageUrl must point to an uploaded image")
+		}
+	}
+	return nil
+}
+
+// validate checks a typing indicator sent by senderID
+func (m *TypingMessage) validate(senderID int) *FrameError {
+	if m.ReceiverID <= 0 {
+		return invalidf("receiverId is required")
+	}
+	if m.ReceiverID == senderID {
+		return invalidf("receiverId cannot be yourself")
+	}
+	return nil
+}
+
+// validate checks a request from readerID to mark a conversation read
+func (m *MarkReadMessage) validate(readerID int) *FrameError {
+	if m.UserID <= 0 {
+		return invalidf("userId is required")
+	}
+	if m.UserID == readerID {
+		return invalidf("userId cannot be yourself")
+	}
+	if m.UpToID <= 0 {
+		return invalidf("upToId is required")
+	}
+	return nil
+}
+
+// validate checks a new post notification
+func (m *PostMessage) validate() *FrameError {
+	if m.PostID <= 0 {
+		return invalidf("postId is required")
+	}
+	return nil
+}
+
+// validate checks a new comment notification
+func (m *CommentMessage) validate() *FrameError {
+	if m.PostID <= 0 {
+		return invalidf("postId is required")
+	}
+	if m.CommentID <= 0 {
+		return invalidf("commentId is required")
+	}
+	return nil
+}
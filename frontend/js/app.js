@@ -92,6 +92,16 @@ const App = {
     }
 };
 
+// Send the user back to the login page when their session runs out
+window.addEventListener('session-expired', () => {
+    if (!AuthService.user) {
+        return;
+    }
+    AuthService.user = null;
+    alert('Your session has expired. Please log in again.');
+    App.renderLogin();
+});
+
 // Initialize the app when the DOM is loaded
 document.addEventListener('DOMContentLoaded', () => {
     App.init();
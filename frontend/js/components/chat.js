@@ -3,6 +3,7 @@ const ChatComponent = {
     activeChat: null,
     users: [],
     messages: {},
+    cursors: {},
     typingUsers: {},
     loadMoreThrottled: null,
     
@@ -89,6 +90,7 @@ const ChatComponent = {
                         <div class="user-name">${user.nickname}</div>
                         ${isTyping ? '<div class="typing-status">typing...</div>' : ''}
                     </div>
+                    ${user.unreadCount ? `<span class="unread-badge">${user.unreadCount}</span>` : ''}
                     <div class="online-indicator ${isOnline ? 'online' : 'offline'}"></div>
                 </div>
             `;
@@ -199,7 +201,7 @@ const ChatComponent = {
             const messageClass = isSent ? 'sent' : 'received';
             const userName = isSent ? 'You' : message.sender.nickname;
             
-            let content = `<div class="message-content">${message.content}</div>`;
+            let content = `<div class="message-content">${message.contentHtml}</div>`;
             
             // Add image if present
             if (message.imageUrl) {
@@ -245,7 +247,9 @@ const ChatComponent = {
         
         try {
             // Load messages
-            const messages = await API.messages.getMessages(userId);
+            const page = await API.messages.getMessages(userId);
+            const messages = page.messages;
+            this.cursors[userId] = page.hasMore ? page.nextCursor : null;
             
             // Update messages container
             const messagesContainer = document.getElementById('messages-list');
@@ -254,6 +258,15 @@ const ChatComponent = {
             // Store messages
             this.messages[userId] = messages || [];
             
+            // Mark the conversation read up to the newest message
+            if (messages && messages.length > 0) {
+                WebSocketService.sendMarkRead(userId, messages[messages.length - 1].id);
+                const chatUser = this.users.find(user => user.id === userId);
+                if (chatUser) {
+                    chatUser.unreadCount = 0;
+                }
+            }
+            
             // Render messages
             if (messages && messages.length > 0) {
                 messagesContainer.innerHTML = this.renderMessages(messages);
@@ -352,12 +365,9 @@ const ChatComponent = {
                 imageUrl = data.url;
             }
             
-            // Send via API
+            // Send via API; the server stores the message and delivers it in real time
             const message = await API.messages.sendMessage(this.activeChat, content, imageUrl);
             
-            // Send via WebSocket for real-time delivery
-            WebSocketService.sendChatMessage(this.activeChat, content, imageUrl);
-            
             // Update local messages
             if (!this.messages[this.activeChat]) {
                 this.messages[this.activeChat] = [];
@@ -371,7 +381,7 @@ const ChatComponent = {
                 messagesContainer.removeChild(noMessages);
             }
             
-            let messageContent = `<div class="message-content">${message.content}</div>`;
+            let messageContent = `<div class="message-content">${message.contentHtml}</div>`;
             if (message.imageUrl) {
                 messageContent += `
                     <div class="message-image">
@@ -411,10 +421,12 @@ const ChatComponent = {
         if (!userId) return;
         
         const currentMessages = this.messages[userId] || [];
-        const offset = currentMessages.length;
+        const cursor = this.cursors[userId];
         
         try {
-            const moreMessages = await API.messages.getMessages(userId, 10, offset);
+            const page = cursor ? await API.messages.getMessages(userId, 10, cursor) : { messages: [] };
+            const moreMessages = page.messages;
+            this.cursors[userId] = page.hasMore ? page.nextCursor : null;
             
             if (moreMessages.length === 0) {
                 // No more messages
@@ -441,7 +453,7 @@ const ChatComponent = {
                 
                 return `
                 <div class="message ${messageClass}">
-                    <div class="message-content">${message.content}</div>
+                    <div class="message-content">${message.contentHtml}</div>
                     <div class="message-meta">
                         <span class="message-sender">${userName}</span>
                         <span class="message-time">${new Date(message.createdAt).toLocaleString()}</span>
@@ -1,5 +1,24 @@
 // frontend/js/components/navigation.js
 const NavigationComponent = {
+    unreadNotifications: 0,
+    
+    // Load the unread notification count and keep it up to date; only once
+    async setupNotifications() {
+        if (this.notificationsReady) {
+            return;
+        }
+        this.notificationsReady = true;
+        
+        WebSocketService.onNotification(this.handleNotification.bind(this));
+        
+        try {
+            const page = await API.notifications.getNotifications();
+            this.updateNotificationBadge(page.unreadCount);
+        } catch (error) {
+            console.error('Error loading notifications:', error);
+        }
+    },
+    
     // Render navigation bar
     renderNavbar() {
         const nav = document.createElement('nav');
@@ -10,10 +29,12 @@ const NavigationComponent = {
                 <h1>Real-Time Forum</h1>
                 <ul>
                     <li><a href="#" id="home-link">Home</a></li>
+                    <li><a href="#" id="notifications-link">Notifications <span class="notification-badge"></span></a></li>
                     <li><a href="#" id="profile-link">My Profile</a></li>
                     <li><a href="#" id="logout-link">Logout</a></li>
                 </ul>
             </div>
+            <div id="notifications-panel" style="display: none;"></div>
         `;
         
         // Add event listeners
@@ -24,6 +45,14 @@ const NavigationComponent = {
                 App.renderHome();
             });
             
+            const notificationsLink = document.getElementById('notifications-link');
+            notificationsLink.addEventListener('click', (e) => {
+                e.preventDefault();
+                this.toggleNotifications();
+            });
+            this.updateNotificationBadge(this.unreadNotifications);
+            this.setupNotifications();
+            
             const profileLink = document.getElementById('profile-link');
             profileLink.addEventListener('click', (e) => {
                 e.preventDefault();
@@ -38,5 +67,98 @@ const NavigationComponent = {
         }, 0);
         
         return nav;
+    },
+    
+    // Show how many notifications are unread next to the link
+    updateNotificationBadge(count) {
+        this.unreadNotifications = count;
+        const badge = document.querySelector('.notification-badge');
+        if (badge) {
+            badge.textContent = count > 0 ? count : '';
+        }
+    },
+    
+    // Count a notification pushed while the user is online, and show it if
+    // the list is open
+    handleNotification(data) {
+        this.updateNotificationBadge(data.unreadCount);
+        
+        const list = document.querySelector('#notifications-panel .notifications-list');
+        if (list && list.offsetParent !== null) {
+            list.querySelector('.no-notifications')?.remove();
+            list.insertAdjacentHTML('afterbegin', this.renderNotification(data.notification));
+        }
+    },
+    
+    // Describe a notification
+    renderNotification(notification) {
+        return `
+            <div class="notification ${notification.read ? '' : 'unread'}" data-post-id="${notification.postId}">
+                <strong>${notification.actor.nickname}</strong> commented on <strong>${notification.postTitle}</strong>
+                <div class="notification-time">${new Date(notification.createdAt).toLocaleString()}</div>
+            </div>
+        `;
+    },
+    
+    // Show or hide the user's notifications; opening them marks them read
+    async toggleNotifications() {
+        const panel = document.getElementById('notifications-panel');
+        if (panel.style.display !== 'none') {
+            panel.style.display = 'none';
+            return;
+        }
+        
+        panel.innerHTML = '<div class="notifications-list"></div>';
+        panel.style.display = 'block';
+        panel.querySelector('.notifications-list').addEventListener('click', (e) => {
+            const item = e.target.closest('.notification');
+            if (item) {
+                panel.style.display = 'none';
+                this.openPost(parseInt(item.dataset.postId));
+            }
+        });
+        
+        await this.loadNotifications();
+    },
+    
+    // Show the first page of notifications, or the next one when cursor is set
+    async loadNotifications(cursor = null) {
+        const list = document.querySelector('#notifications-panel .notifications-list');
+        
+        try {
+            const page = await API.notifications.getNotifications(cursor);
+            const html = page.items.map(notification => this.renderNotification(notification)).join('');
+            
+            if (cursor) {
+                list.querySelector('.load-more-notifications-btn').remove();
+                list.insertAdjacentHTML('beforeend', html);
+            } else {
+                list.innerHTML = html || '<p class="no-notifications">No notifications.</p>';
+            }
+            if (page.nextCursor) {
+                list.insertAdjacentHTML('beforeend', '<button class="load-more-notifications-btn">Load more</button>');
+                list.querySelector('.load-more-notifications-btn').addEventListener('click', (e) => {
+                    e.stopPropagation();
+                    this.loadNotifications(page.nextCursor);
+                });
+            }
+            
+            // Everything up to the newest shown has now been seen
+            if (!cursor && page.items.length > 0 && page.unreadCount > 0) {
+                const result = await API.notifications.markRead(page.items[0].id);
+                this.updateNotificationBadge(result.unreadCount);
+            }
+        } catch (error) {
+            alert('Error loading notifications: ' + error.message);
+        }
+    },
+    
+    // Open a post from anywhere, going back to the home page first if the
+    // posts aren't shown
+    async openPost(postId) {
+        if (!document.querySelector('.content')) {
+            await App.renderHome();
+        }
+        PostsComponent.handleViewComments(postId);
     }
 };
\ No newline at end of file
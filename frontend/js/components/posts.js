@@ -1,7 +1,11 @@
 // frontend/js/components/posts.js
 const PostsComponent = {
     posts: [],
+    pinnedPosts: [],
+    categories: [],
     currentPost: null,
+    nextCursor: null,
+    feedFilters: { sort: 'newest', window: '', category: '', archived: false, tags: '' },
     
     // Render posts feed
     async renderPosts() {
@@ -10,67 +14,125 @@ const PostsComponent = {
         
         try {
             // Load posts
-            this.posts = await API.posts.getAllPosts() || [];
+            const page = await API.posts.getAllPosts(this.feedFilters);
+            this.posts = page.items;
+            this.pinnedPosts = page.pinned || [];
+            this.nextCursor = page.nextCursor;
+            this.categories = await API.categories.getCategories() || [];
             
             // Create HTML
             let postsHTML = '';
             
-            if (!this.posts || this.posts.length === 0) {
+            if ((!this.posts || this.posts.length === 0) && this.pinnedPosts.length === 0) {
                 postsHTML = '<p>No posts yet. Be the first to create one!</p>';
             } else {
-                postsHTML = this.posts.map(post => `
-                    <div class="post" data-post-id="${post.id}">
-                        <div class="post-header">
-                            <h3>${post.title}</h3>
-                            <span class="post-category">${post.category}</span>
-                        </div>
-                        <div class="post-meta">
-                            Posted by ${post.user.nickname} on ${new Date(post.createdAt).toLocaleString()}
-                        </div>
-                        <div class="post-content">
-                            ${post.content}
-                        </div>
-                        <div class="post-actions">
-                            <button class="view-comments-btn" data-post-id="${post.id}">
-                                View Comments
-                            </button>
-                        </div>
-                    </div>
-                `).join('');
+                postsHTML = this.posts.map(post => this.renderPostCard(post)).join('');
             }
             
             container.innerHTML = `
                 <div class="posts-header">
-                    <h2>Recent Posts</h2>
-                    <button id="new-post-btn">Create New Post</button>
+                    <h2>${this.feedFilters.tags ? `Posts tagged ${this.renderTags(this.feedFilters.tags.split(','))}` : 'Recent Posts'}</h2>
+                    <div>
+                        <button id="bookmarks-btn">My Bookmarks</button>
+                        <button id="drafts-btn">My Drafts</button>
+                        <button id="new-post-btn">Create New Post</button>
+                    </div>
+                </div>
+                <div id="drafts-container" style="display: none;"></div>
+                <div id="bookmarks-container" style="display: none;"></div>
+                <div class="posts-filters">
+                    <form id="search-form">
+                        <input type="search" id="search-input" placeholder="Search posts, comments and messages">
+                    </form>
+                    <select id="feed-sort">
+                        <option value="newest">Newest</option>
+                        <option value="comments">Most commented</option>
+                        <option value="activity">Recently active</option>
+                        <option value="hot">Hot</option>
+                        <option value="top">Top</option>
+                    </select>
+                    <select id="feed-window">
+                        <option value="">All time</option>
+                        <option value="day">Past day</option>
+                        <option value="week">Past week</option>
+                        <option value="month">Past month</option>
+                    </select>
+                    <select id="feed-category">
+                        <option value="">All categories</option>
+                        ${this.categories.map(category => `
+                            <option value="${category.slug}">${category.name}</option>
+                        `).join('')}
+                    </select>
+                    <label class="feed-archived">
+                        <input type="checkbox" id="feed-archived"> Show archived
+                    </label>
+                    ${this.feedFilters.tags ? '<button id="clear-tags-btn">All tags</button>' : ''}
+                </div>
+                <div class="pinned-posts" style="display: ${this.pinnedPosts.length ? 'block' : 'none'};">
+                    ${this.pinnedPosts.map(post => this.renderPostCard(post)).join('')}
                 </div>
                 <div class="posts-container">
                     ${postsHTML}
                 </div>
+                <button id="load-more-posts-btn" style="display: ${this.nextCursor ? 'block' : 'none'};">Load more</button>
                 <div id="post-form-container" style="display: none;">
                     <h3>Create a New Post</h3>
                     <form id="post-form">
                         <div class="form-group">
                             <label for="post-title">Title</label>
-                            <input type="text" id="post-title" name="title" required>
+                            <input type="text" id="post-title" name="title" maxlength="200" required>
                         </div>
                         <div class="form-group">
-                            <label for="post-category">Category</label>
-                            <select id="post-category" name="category" required>
-                                <option value="">Select a category</option>
-                                <option value="General">General</option>
-                                <option value="Technology">Technology</option>
-                                <option value="Sports">Sports</option>
-                                <option value="Gaming">Gaming</option>
-                                <option value="Movies">Movies</option>
-                                <option value="Music">Music</option>
-                                <option value="Other">Other</option>
+                            <label for="post-category">Categories</label>
+                            <select id="post-category" name="categories" multiple required>
+                                ${this.categories.map(category => `
+                                    <option value="${category.id}">${category.name}</option>
+                                `).join('')}
                             </select>
                         </div>
+                        <div class="form-group">
+                            <label for="post-tags">Tags (optional, comma-separated)</label>
+                            <input type="text" id="post-tags" name="tags" list="tag-suggestions" autocomplete="off"
+                                   placeholder="e.g. release-2.3, bug">
+                            <datalist id="tag-suggestions"></datalist>
+                        </div>
                         <div class="form-group">
                             <label for="post-content">Content</label>
-                            <textarea id="post-content" name="content" rows="5" required></textarea>
+                            <textarea id="post-content" name="content" rows="5" maxlength="20000" required></textarea>
+                        </div>
+                        <div class="form-group">
+                            <label for="post-attachments">Attachments (optional)</label>
+                            <input type="file" id="post-attachments" multiple
+                                   accept="image/*,.pdf,.txt,.zip">
+                        </div>
+                        <div class="form-group">
+                            <label>
+                                <input type="checkbox" id="post-poll-enabled"> Add a poll
+                            </label>
                         </div>
+                        <div id="post-poll-fields" style="display: none;">
+                            <div class="form-group">
+                                <label for="post-poll-question">Question</label>
+                                <input type="text" id="post-poll-question">
+                            </div>
+                            <div class="form-group">
+                                <label for="post-poll-options">Options, one per line</label>
+                                <textarea id="post-poll-options" rows="4"></textarea>
+                            </div>
+                            <div class="form-group">
+                                <label><input type="checkbox" id="post-poll-multiple"> Allow several choices</label>
+                                <label><input type="checkbox" id="post-poll-anonymous"> Anonymous votes</label>
+                            </div>
+                            <div class="form-group">
+                                <label for="post-poll-closes-at">Close voting at (optional)</label>
+                                <input type="datetime-local" id="post-poll-closes-at">
+                            </div>
+                        </div>
+                        <div class="form-group">
+                            <label for="post-publish-at">Publish later (optional)</label>
+                            <input type="datetime-local" id="post-publish-at" name="publishAt">
+                        </div>
+                        <div class="draft-status" id="draft-status"></div>
                         <div class="btn-group">
                             <button type="button" id="cancel-post-btn">Cancel</button>
                             <button type="submit">Create Post</button>
@@ -95,6 +157,79 @@ const PostsComponent = {
                     const postForm = document.getElementById('post-form');
                     if (postForm) {
                         postForm.addEventListener('submit', this.handleCreatePost.bind(this));
+                        postForm.addEventListener('input', this.scheduleAutosave.bind(this));
+                        postForm.addEventListener('change', this.scheduleAutosave.bind(this));
+                    }
+                    
+                    const draftsBtn = document.getElementById('drafts-btn');
+                    if (draftsBtn) {
+                        draftsBtn.addEventListener('click', this.toggleDrafts.bind(this));
+                    }
+                    
+                    const bookmarksBtn = document.getElementById('bookmarks-btn');
+                    if (bookmarksBtn) {
+                        bookmarksBtn.addEventListener('click', this.toggleBookmarks.bind(this));
+                    }
+                    
+                    const sortSelect = document.getElementById('feed-sort');
+                    const windowSelect = document.getElementById('feed-window');
+                    const categorySelect = document.getElementById('feed-category');
+                    const archivedCheckbox = document.getElementById('feed-archived');
+                    if (sortSelect && windowSelect && categorySelect && archivedCheckbox) {
+                        sortSelect.value = this.feedFilters.sort;
+                        windowSelect.value = this.feedFilters.window;
+                        categorySelect.value = this.feedFilters.category;
+                        archivedCheckbox.checked = this.feedFilters.archived;
+                        const applyFilters = () => {
+                            this.feedFilters = {
+                                ...this.feedFilters,
+                                sort: sortSelect.value,
+                                window: windowSelect.value,
+                                category: categorySelect.value,
+                                archived: archivedCheckbox.checked
+                            };
+                            App.renderHome();
+                        };
+                        sortSelect.addEventListener('change', applyFilters);
+                        windowSelect.addEventListener('change', applyFilters);
+                        categorySelect.addEventListener('change', applyFilters);
+                        archivedCheckbox.addEventListener('change', applyFilters);
+                    }
+                    
+                    const clearTagsBtn = document.getElementById('clear-tags-btn');
+                    if (clearTagsBtn) {
+                        clearTagsBtn.addEventListener('click', () => this.showTag(''));
+                    }
+                    
+                    const pollCheckbox = document.getElementById('post-poll-enabled');
+                    if (pollCheckbox) {
+                        pollCheckbox.addEventListener('change', () => {
+                            document.getElementById('post-poll-fields').style.display = pollCheckbox.checked ? 'block' : 'none';
+                        });
+                    }
+                    
+                    const tagsInput = document.getElementById('post-tags');
+                    if (tagsInput) {
+                        tagsInput.addEventListener('input', this.suggestTags.bind(this));
+                    }
+                    
+                    // Tags link to their tag page, in the feed and on a post
+                    container.addEventListener('click', (e) => {
+                        const tag = e.target.closest('.post-tag');
+                        if (tag) {
+                            e.preventDefault();
+                            this.showTag(tag.dataset.tag);
+                        }
+                    });
+                    
+                    const searchForm = document.getElementById('search-form');
+                    if (searchForm) {
+                        searchForm.addEventListener('submit', this.handleSearch.bind(this));
+                    }
+                    
+                    const loadMoreBtn = document.getElementById('load-more-posts-btn');
+                    if (loadMoreBtn) {
+                        loadMoreBtn.addEventListener('click', this.loadMorePosts.bind(this));
                     }
                     
                     const viewCommentsBtns = document.querySelectorAll('.view-comments-btn');
@@ -107,6 +242,10 @@ const PostsComponent = {
                         });
                     }
                     
+                    this.setupReactions();
+                    this.setupBookmarks();
+                    this.setupPolls();
+                    
                     // Register for real-time updates
                     if (WebSocketService && typeof WebSocketService.onNewPost === 'function') {
                         WebSocketService.onNewPost(this.handleNewPost.bind(this));
@@ -138,22 +277,659 @@ const PostsComponent = {
         return container;
     },
     
+    // Render a post in the feed
+    renderPostCard(post) {
+        return `
+            <div class="post" data-post-id="${post.id}">
+                <div class="post-header">
+                    <h3>${post.title}</h3>
+                    ${this.renderPostBadges(post)}
+                    ${this.renderCategories(post)}
+                </div>
+                <div class="post-tags">${this.renderTags(post.tags)}</div>
+                <div class="post-meta">
+                    Posted by ${post.user.nickname} on ${new Date(post.createdAt).toLocaleString()}${post.edited ? " (edited)" : ""}
+                    · ${post.views || 0} views
+                </div>
+                <div class="post-content">
+                    ${post.contentHtml}
+                </div>
+                ${this.renderAttachments(post.attachments)}
+                ${this.renderPoll(post)}
+                <div class="post-actions">
+                    ${this.renderReactions('post', post)}
+                    ${this.renderBookmarkButton('post', post)}
+                    <button class="view-comments-btn" data-post-id="${post.id}">
+                        View Comments (${post.commentCount || 0})
+                    </button>
+                </div>
+            </div>
+        `;
+    },
+    
+    // Append the next page of the feed
+    async loadMorePosts() {
+        if (!this.nextCursor) {
+            return;
+        }
+        
+        try {
+            const page = await API.posts.getAllPosts({ ...this.feedFilters, cursor: this.nextCursor });
+            this.posts = this.posts.concat(page.items);
+            this.nextCursor = page.nextCursor;
+            
+            const postsContainer = document.querySelector('.posts-container');
+            page.items.forEach(post => {
+                postsContainer.insertAdjacentHTML('beforeend', this.renderPostCard(post));
+                const btn = postsContainer.querySelector(`.view-comments-btn[data-post-id="${post.id}"]`);
+                btn.addEventListener('click', () => this.handleViewComments(post.id));
+            });
+            
+            document.getElementById('load-more-posts-btn').style.display = this.nextCursor ? 'block' : 'none';
+        } catch (error) {
+            alert('Error loading posts: ' + error.message);
+        }
+    },
+    
+    // Show search results in place of the feed
+    async handleSearch(e) {
+        e.preventDefault();
+        
+        const q = document.getElementById('search-input').value.trim();
+        if (!q) {
+            App.renderHome();
+            return;
+        }
+        
+        try {
+            const page = await API.search({ q, category: this.feedFilters.category });
+            const postsContainer = document.querySelector('.posts-container');
+            document.getElementById('load-more-posts-btn').style.display = 'none';
+            
+            if (page.items.length === 0) {
+                postsContainer.innerHTML = '<p>No results found.</p>';
+                return;
+            }
+            
+            // Snippets are escaped by the server apart from their <mark> tags
+            postsContainer.innerHTML = page.items.map(result => `
+                <div class="post search-result" data-type="${result.type}" data-post-id="${result.postId || ''}">
+                    <div class="post-meta">
+                        ${result.type === 'message' ? 'Message from' : result.type === 'comment' ? 'Comment by' : 'Post by'}
+                        ${result.user.nickname} on ${new Date(result.createdAt).toLocaleString()}
+                        ${result.title ? `in <strong>${result.title}</strong>` : ''}
+                    </div>
+                    <div class="post-content">${result.snippet}</div>
+                </div>
+            `).join('');
+            
+            postsContainer.querySelectorAll('.search-result[data-post-id]').forEach(el => {
+                if (el.dataset.postId) {
+                    el.addEventListener('click', () => this.handleViewComments(parseInt(el.dataset.postId)));
+                }
+            });
+        } catch (error) {
+            alert('Error searching: ' + error.message);
+        }
+    },
+    
+    // Render like and dislike buttons for a post or comment
+    renderReactions(targetType, target) {
+        return `
+            <div class="reactions" data-target-type="${targetType}" data-target-id="${target.id}">
+                <button class="reaction-btn ${target.myReaction === 'like' ? 'active' : ''}" data-reaction="like">
+                    👍 <span class="reaction-count">${target.likes || 0}</span>
+                </button>
+                <button class="reaction-btn ${target.myReaction === 'dislike' ? 'active' : ''}" data-reaction="dislike">
+                    👎 <span class="reaction-count">${target.dislikes || 0}</span>
+                </button>
+            </div>
+        `;
+    },
+    
+    // Handle reaction clicks anywhere on the page and live count updates; only once
+    setupReactions() {
+        if (this.reactionsReady) {
+            return;
+        }
+        this.reactionsReady = true;
+        
+        document.addEventListener('click', async (e) => {
+            const button = e.target.closest('.reaction-btn');
+            if (!button) {
+                return;
+            }
+            
+            const container = button.closest('.reactions');
+            const { targetType, targetId } = container.dataset;
+            try {
+                const reactions = await API.posts.react(targetType, targetId, button.dataset.reaction);
+                this.updateReactions(targetType, parseInt(targetId), reactions, true);
+            } catch (error) {
+                alert('Error saving reaction: ' + error.message);
+            }
+        });
+        
+        WebSocketService.onReactionUpdate(update => {
+            this.updateReactions(update.targetType, update.targetId, update, false);
+        });
+    },
+    
+    // Render the button saving a post or comment to the user's bookmarks
+    renderBookmarkButton(targetType, target) {
+        return `
+            <button class="bookmark-btn ${target.isBookmarked ? 'active' : ''}"
+                    data-target-type="${targetType}" data-target-id="${target.id}">
+                ${target.isBookmarked ? 'Saved' : 'Save'}
+            </button>
+        `;
+    },
+    
+    // Handle bookmark clicks anywhere on the page; only once. Saving asks for
+    // an optional folder.
+    setupBookmarks() {
+        if (this.bookmarksReady) {
+            return;
+        }
+        this.bookmarksReady = true;
+        
+        document.addEventListener('click', async (e) => {
+            const button = e.target.closest('.bookmark-btn');
+            if (!button) {
+                return;
+            }
+            
+            const { targetType, targetId } = button.dataset;
+            try {
+                if (button.classList.contains('active')) {
+                    await API.posts.unbookmark(targetType, targetId);
+                    this.updateBookmarkButtons(targetType, targetId, false);
+                    return;
+                }
+                
+                const folder = prompt('Save to folder (optional):', '');
+                if (folder === null) {
+                    return;
+                }
+                await API.posts.bookmark(targetType, targetId, folder);
+                this.updateBookmarkButtons(targetType, targetId, true);
+            } catch (error) {
+                alert('Error saving bookmark: ' + error.message);
+            }
+        });
+    },
+    
+    updateBookmarkButtons(targetType, targetId, bookmarked) {
+        document.querySelectorAll(`.bookmark-btn[data-target-type="${targetType}"][data-target-id="${targetId}"]`).forEach(button => {
+            button.classList.toggle('active', bookmarked);
+            button.textContent = bookmarked ? 'Saved' : 'Save';
+        });
+    },
+    
+    // Show or hide the user's bookmarks
+    async toggleBookmarks() {
+        const container = document.getElementById('bookmarks-container');
+        if (container.style.display !== 'none') {
+            container.style.display = 'none';
+            return;
+        }
+        
+        try {
+            const folders = await API.posts.getBookmarkFolders();
+            container.innerHTML = `
+                <div class="bookmarks-header">
+                    <h3>Bookmarks</h3>
+                    <select id="bookmark-folder">
+                        <option value="">All folders</option>
+                        ${folders.filter(folder => folder.name).map(folder => `
+                            <option value="${folder.name}">${folder.name} (${folder.count})</option>
+                        `).join('')}
+                    </select>
+                </div>
+                <div class="bookmarks-list"></div>
+            `;
+            container.style.display = 'block';
+            
+            const folderSelect = document.getElementById('bookmark-folder');
+            folderSelect.addEventListener('change', () => this.loadBookmarks(folderSelect.value));
+            await this.loadBookmarks('');
+        } catch (error) {
+            alert('Error loading bookmarks: ' + error.message);
+        }
+    },
+    
+    // Show the first page of bookmarks in a folder, or the next one when
+    // cursor is given
+    async loadBookmarks(folder, cursor = null) {
+        const list = document.querySelector('#bookmarks-container .bookmarks-list');
+        
+        try {
+            const page = await API.posts.getBookmarks({ folder, cursor });
+            const html = page.items.map(bookmark => `
+                <div class="bookmark">
+                    ${bookmark.folder ? `<div class="bookmark-folder">${bookmark.folder}</div>` : ''}
+                    ${bookmark.comment ? `
+                        <div class="comment">
+                            <div class="comment-meta">
+                                Comment by ${bookmark.comment.user.nickname} on <strong>${bookmark.post.title}</strong>
+                            </div>
+                            <div class="comment-content">${bookmark.comment.contentHtml}</div>
+                            <div class="comment-actions">
+                                ${this.renderBookmarkButton('comment', bookmark.comment)}
+                                <button class="view-comments-btn" data-post-id="${bookmark.post.id}">View Post</button>
+                            </div>
+                        </div>
+                    ` : this.renderPostCard(bookmark.post)}
+                </div>
+            `).join('');
+            
+            if (cursor) {
+                list.querySelector('.load-more-bookmarks-btn').remove();
+                list.insertAdjacentHTML('beforeend', html);
+            } else {
+                list.innerHTML = html || '<p>No bookmarks.</p>';
+            }
+            if (page.nextCursor) {
+                list.insertAdjacentHTML('beforeend', '<button class="load-more-bookmarks-btn">Load more</button>');
+                list.querySelector('.load-more-bookmarks-btn').addEventListener('click', () => {
+                    this.loadBookmarks(folder, page.nextCursor);
+                });
+            }
+            
+            list.querySelectorAll('.view-comments-btn:not([data-ready])').forEach(button => {
+                button.dataset.ready = 'true';
+                button.addEventListener('click', () => this.handleViewComments(parseInt(button.dataset.postId)));
+            });
+        } catch (error) {
+            alert('Error loading bookmarks: ' + error.message);
+        }
+    },
+    
+    // Show new counts for a post or comment, and the user's own reaction if known
+    updateReactions(targetType, targetId, reactions, own) {
+        document.querySelectorAll(`.reactions[data-target-type="${targetType}"][data-target-id="${targetId}"]`).forEach(container => {
+            container.querySelectorAll('.reaction-btn').forEach(button => {
+                const reaction = button.dataset.reaction;
+                button.querySelector('.reaction-count').textContent = reaction === 'like' ? reactions.likes : reactions.dislikes;
+                if (own) {
+                    button.classList.toggle('active', reactions.myReaction === reaction);
+                }
+            });
+        });
+    },
+    
+    // Render a post's poll with its tallies, as a ballot while it is open
+    // Show attached images inline and other files as download links
+    renderAttachments(attachments) {
+        if (!attachments || attachments.length === 0) {
+            return '';
+        }
+        
+        return `
+            <div class="attachments">
+                ${attachments.map(attachment => attachment.type.startsWith('image/') ? `
+                    <a href="${attachment.url}" target="_blank" rel="noopener">
+                        <img class="attachment-image" src="${attachment.url}" alt="${attachment.filename}"
+                             ${attachment.width ? `width="${attachment.width}" height="${attachment.height}"` : ''}
+                             loading="lazy">
+                    </a>
+                ` : `
+                    <a class="attachment-file" href="${attachment.url}" download="${attachment.filename}">
+                        ${attachment.filename} <span class="attachment-size">(${this.formatFileSize(attachment.size)})</span>
+                    </a>
+                `).join('')}
+            </div>
+        `;
+    },
+    
+    formatFileSize(size) {
+        if (size < 1024) {
+            return `${size} B`;
+        }
+        if (size < 1024 * 1024) {
+            return `${(size / 1024).toFixed(1)} KB`;
+        }
+        return `${(size / (1024 * 1024)).toFixed(1)} MB`;
+    },
+    
+    // Upload the files chosen in a file input and return their attachment IDs
+    async uploadAttachments(input) {
+        if (!input) {
+            return [];
+        }
+        const uploads = await Promise.all(Array.from(input.files).map(file => API.posts.uploadFile(file)));
+        return uploads.map(upload => upload.id);
+    },
+    
+    renderPoll(post) {
+        const poll = post.poll;
+        if (!poll) {
+            return '';
+        }
+        
+        const open = !poll.closed && !post.archived && AuthService.user;
+        const notes = [
+            poll.multiple ? 'Pick any' : 'Pick one',
+            poll.anonymous ? 'anonymous' : 'public votes',
+            poll.closed ? 'closed' : poll.closesAt ? `closes ${new Date(poll.closesAt).toLocaleString()}` : ''
+        ].filter(note => note);
+        
+        return `
+            <div class="poll" data-poll-id="${poll.id}">
+                <div class="poll-question">${poll.question}</div>
+                <div class="poll-meta">${notes.join(' · ')}</div>
+                <form class="poll-form">
+                    ${poll.options.map(option => `
+                        <label class="poll-option" data-option-id="${option.id}">
+                            <span class="poll-option-text">
+                                ${open ? `
+                                    <input type="${poll.multiple ? 'checkbox' : 'radio'}" name="poll-${poll.id}" value="${option.id}"
+                                           ${(poll.myVotes || []).includes(option.id) ? 'checked' : ''}>
+                                ` : ''}
+                                ${option.text}
+                            </span>
+                            <span class="poll-votes">${option.votes}</span>
+                            <span class="poll-bar"><span style="width: ${this.pollPercent(option.votes, poll.totalVoters)}%;"></span></span>
+                            <span class="poll-voters">${(option.voters || []).join(', ')}</span>
+                        </label>
+                    `).join('')}
+                    ${open ? `
+                        <button type="submit">Vote</button>
+                        <button type="button" class="unvote-btn">Remove vote</button>
+                    ` : ''}
+                </form>
+                <div class="poll-total">${poll.totalVoters} voted</div>
+            </div>
+        `;
+    },
+    
+    pollPercent(votes, totalVoters) {
+        return totalVoters ? Math.round(votes * 100 / totalVoters) : 0;
+    },
+    
+    // Handle ballots anywhere on the page and live tally updates; only once
+    setupPolls() {
+        if (this.pollsReady) {
+            return;
+        }
+        this.pollsReady = true;
+        
+        document.addEventListener('submit', async (e) => {
+            const form = e.target.closest('.poll-form');
+            if (!form) {
+                return;
+            }
+            e.preventDefault();
+            
+            const pollId = parseInt(form.closest('.poll').dataset.pollId);
+            const optionIds = Array.from(form.querySelectorAll('input:checked')).map(input => parseInt(input.value));
+            try {
+                this.updatePoll(await API.posts.vote(pollId, optionIds));
+            } catch (error) {
+                alert('Error voting: ' + error.message);
+            }
+        });
+        
+        document.addEventListener('click', async (e) => {
+            const button = e.target.closest('.unvote-btn');
+            if (!button) {
+                return;
+            }
+            
+            const form = button.closest('.poll-form');
+            try {
+                this.updatePoll(await API.posts.unvote(parseInt(form.closest('.poll').dataset.pollId)));
+                form.querySelectorAll('input').forEach(input => input.checked = false);
+            } catch (error) {
+                alert('Error removing vote: ' + error.message);
+            }
+        });
+        
+        WebSocketService.onPollUpdate(update => {
+            this.updatePoll({
+                id: update.pollId,
+                totalVoters: update.totalVoters,
+                options: update.options.map(tally => ({ id: tally.optionId, votes: tally.votes, voters: tally.voters }))
+            });
+        });
+    },
+    
+    // Show new tallies for a poll wherever it is on the page
+    updatePoll(poll) {
+        document.querySelectorAll(`.poll[data-poll-id="${poll.id}"]`).forEach(container => {
+            poll.options.forEach(option => {
+                const row = container.querySelector(`.poll-option[data-option-id="${option.id}"]`);
+                if (!row) {
+                    return;
+                }
+                row.querySelector('.poll-votes').textContent = option.votes;
+                row.querySelector('.poll-bar span').style.width = `${this.pollPercent(option.votes, poll.totalVoters)}%`;
+                row.querySelector('.poll-voters').textContent = (option.voters || []).join(', ');
+            });
+            container.querySelector('.poll-total').textContent = `${poll.totalVoters} voted`;
+        });
+    },
+    
+    // Render the category labels of a post
+    renderCategories(post) {
+        return (post.categories || []).map(category => `
+            <span class="post-category">${category.name}</span>
+        `).join('');
+    },
+    
+    // Render tags as links to their tag pages
+    renderTags(tags) {
+        return (tags || []).map(tag => `
+            <a href="#" class="post-tag" data-tag="${tag}">#${tag}</a>
+        `).join('');
+    },
+    
+    // Show the feed of posts with a tag, or of all posts when tag is empty
+    showTag(tag) {
+        this.feedFilters = { ...this.feedFilters, tags: tag };
+        this.currentPost = null;
+        App.renderHome();
+    },
+    
+    // Suggest existing tags for the one being typed, keeping those before it
+    async suggestTags(e) {
+        const value = e.target.value;
+        const comma = value.lastIndexOf(',');
+        const before = comma === -1 ? '' : value.slice(0, comma + 1) + ' ';
+        const prefix = value.slice(comma + 1).trim();
+        
+        const list = document.getElementById('tag-suggestions');
+        if (!prefix) {
+            list.innerHTML = '';
+            return;
+        }
+        
+        try {
+            const tags = await API.tags.getTags(prefix);
+            list.innerHTML = tags.map(tag => `
+                <option value="${before}${tag.name}">${tag.postCount} posts</option>
+            `).join('');
+        } catch (error) {
+            console.error('Error loading tag suggestions:', error);
+        }
+    },
+    
     // Toggle post form visibility
     togglePostForm() {
         const formContainer = document.getElementById('post-form-container');
         formContainer.style.display = formContainer.style.display === 'none' ? 'block' : 'none';
     },
     
-    // Handle creating a new post
+    // Read the post form; publishAt is null unless a time was picked, and poll
+    // unless one was added
+    readPostForm() {
+        const publishAt = document.getElementById('post-publish-at').value;
+        const pollClosesAt = document.getElementById('post-poll-closes-at').value;
+        const poll = document.getElementById('post-poll-enabled').checked ? {
+            question: document.getElementById('post-poll-question').value,
+            options: document.getElementById('post-poll-options').value.split('\n'),
+            multiple: document.getElementById('post-poll-multiple').checked,
+            anonymous: document.getElementById('post-poll-anonymous').checked,
+            closesAt: pollClosesAt ? new Date(pollClosesAt).toISOString() : null
+        } : null;
+        return {
+            id: this.draftId || 0,
+            title: document.getElementById('post-title').value,
+            categoryIds: Array.from(document.getElementById('post-category').selectedOptions)
+                .map(option => parseInt(option.value)),
+            content: document.getElementById('post-content').value,
+            tags: document.getElementById('post-tags').value.split(',').map(tag => tag.trim()).filter(tag => tag),
+            publishAt: publishAt ? new Date(publishAt).toISOString() : null,
+            poll
+        };
+    },
+    
+    // Save the post being written as a draft a moment after the user stops typing
+    scheduleAutosave() {
+        clearTimeout(this.autosaveTimer);
+        this.autosaveTimer = setTimeout(() => this.autosaveDraft(), 2000);
+    },
+    
+    async autosaveDraft() {
+        const draft = this.readPostForm();
+        if (!draft.title && !draft.content) {
+            return;
+        }
+        
+        // Scheduling is only done on submit; leave any schedule as it is
+        delete draft.publishAt;
+        
+        const status = document.getElementById('draft-status');
+        try {
+            const saved = await API.posts.saveDraft(draft);
+            this.draftId = saved.id;
+            if (status) {
+                status.textContent = `Draft saved at ${new Date().toLocaleTimeString()}`;
+            }
+        } catch (error) {
+            if (status) {
+                status.textContent = 'Draft not saved: ' + error.message;
+            }
+        }
+    },
+    
+    // Forget the draft being written once it has been posted or scheduled
+    resetPostForm() {
+        clearTimeout(this.autosaveTimer);
+        this.draftId = null;
+        document.getElementById('post-form').reset();
+        document.getElementById('post-poll-fields').style.display = 'none';
+        document.getElementById('draft-status').textContent = '';
+    },
+    
+    // Show or hide the user's drafts
+    async toggleDrafts() {
+        const container = document.getElementById('drafts-container');
+        if (container.style.display !== 'none') {
+            container.style.display = 'none';
+            return;
+        }
+        
+        try {
+            const drafts = await API.posts.getDrafts();
+            container.innerHTML = drafts.length === 0 ? '<p>No drafts.</p>' : drafts.map(draft => `
+                <div class="draft" data-draft-id="${draft.id}">
+                    <div>
+                        <strong>${draft.title || '(untitled)'}</strong>
+                        <span class="draft-meta">
+                            saved ${new Date(draft.createdAt).toLocaleString()}
+                            ${draft.publishAt ? ` · scheduled for ${new Date(draft.publishAt).toLocaleString()}` : ''}
+                        </span>
+                    </div>
+                    <div>
+                        <button class="edit-draft-btn">Edit</button>
+                        <button class="discard-draft-btn">Discard</button>
+                    </div>
+                </div>
+            `).join('');
+            container.style.display = 'block';
+            
+            drafts.forEach(draft => {
+                const item = container.querySelector(`.draft[data-draft-id="${draft.id}"]`);
+                item.querySelector('.edit-draft-btn').addEventListener('click', () => this.editDraft(draft));
+                item.querySelector('.discard-draft-btn').addEventListener('click', async () => {
+                    try {
+                        await API.posts.deletePost(draft.id);
+                        item.remove();
+                        if (this.draftId === draft.id) {
+                            this.resetPostForm();
+                        }
+                    } catch (error) {
+                        alert('Error discarding draft: ' + error.message);
+                    }
+                });
+            });
+        } catch (error) {
+            alert('Error loading drafts: ' + error.message);
+        }
+    },
+    
+    // Load a draft into the post form to carry on writing it
+    editDraft(draft) {
+        this.draftId = draft.id;
+        document.getElementById('post-title').value = draft.title;
+        document.getElementById('post-content').value = draft.content;
+        document.getElementById('post-tags').value = (draft.tags || []).join(', ');
+        const categoryIds = draft.categories.map(category => category.id);
+        Array.from(document.getElementById('post-category').options).forEach(option => {
+            option.selected = categoryIds.includes(parseInt(option.value));
+        });
+        
+        // datetime-local wants local time without a zone
+        let publishAt = '';
+        if (draft.publishAt) {
+            const date = new Date(draft.publishAt);
+            publishAt = new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+        }
+        document.getElementById('post-publish-at').value = publishAt;
+        document.getElementById('draft-status').textContent = 'Editing draft';
+        
+        document.getElementById('post-form-container').style.display = 'block';
+        document.getElementById('drafts-container').style.display = 'none';
+    },
+    
+    // Handle creating a new post, publishing the draft it was autosaved to
+    // or scheduling it when a publish time was picked
     async handleCreatePost(e) {
         e.preventDefault();
+        clearTimeout(this.autosaveTimer);
         
-        const title = document.getElementById('post-title').value;
-        const category = document.getElementById('post-category').value;
-        const content = document.getElementById('post-content').value;
+        const post = this.readPostForm();
         
         try {
-            const newPost = await API.posts.createPost({ title, category, content });
+            // Drafts don't keep polls or attachments, so a post with either is
+            // created directly
+            const attachmentInput = document.getElementById('post-attachments');
+            const hasAttachments = attachmentInput.files.length > 0;
+            if (post.publishAt && (post.poll || hasAttachments)) {
+                alert('Posts with a poll or attachments can\'t be scheduled');
+                return;
+            }
+            post.attachmentIds = await this.uploadAttachments(attachmentInput);
+            
+            if (post.publishAt) {
+                const draft = await API.posts.saveDraft(post);
+                alert(`Post scheduled for ${new Date(draft.publishAt).toLocaleString()}`);
+                this.resetPostForm();
+                this.togglePostForm();
+                return;
+            }
+            
+            let newPost;
+            if (this.draftId && !post.poll && !hasAttachments) {
+                await API.posts.saveDraft(post);
+                newPost = await API.posts.publishDraft(this.draftId);
+            } else {
+                newPost = await API.posts.createPost(post);
+                if (this.draftId) {
+                    await API.posts.deletePost(this.draftId);
+                }
+            }
             
             // Add to the posts array
             this.posts.unshift(newPost);
@@ -163,25 +939,7 @@ const PostsComponent = {
             
             // Update the UI
             const postsContainer = document.querySelector('.posts-container');
-            const postHTML = `
-                <div class="post" data-post-id="${newPost.id}">
-                    <div class="post-header">
-                        <h3>${newPost.title}</h3>
-                        <span class="post-category">${newPost.category}</span>
-                    </div>
-                    <div class="post-meta">
-                        Posted by ${newPost.user.nickname} on ${new Date(newPost.createdAt).toLocaleString()}
-                    </div>
-                    <div class="post-content">
-                        ${newPost.content}
-                    </div>
-                    <div class="post-actions">
-                        <button class="view-comments-btn" data-post-id="${newPost.id}">
-                            View Comments
-                        </button>
-                    </div>
-                </div>
-            `;
+            const postHTML = this.renderPostCard(newPost);
             
             postsContainer.insertAdjacentHTML('afterbegin', postHTML);
             
@@ -192,7 +950,7 @@ const PostsComponent = {
             });
             
             // Reset form and hide it
-            document.getElementById('post-form').reset();
+            this.resetPostForm();
             this.togglePostForm();
             
         } catch (error) {
@@ -217,31 +975,53 @@ const PostsComponent = {
                     <div class="post">
                         <div class="post-header">
                             <h2>${post.title}</h2>
-                            <span class="post-category">${post.category}</span>
+                            ${this.renderPostBadges(post)}
+                            ${this.renderCategories(post)}
                         </div>
+                        <div class="post-tags">${this.renderTags(post.tags)}</div>
                         <div class="post-meta">
-                            Posted by ${post.user.nickname} on ${new Date(post.createdAt).toLocaleString()}
+                            Posted by ${post.user.nickname} on ${new Date(post.createdAt).toLocaleString()}${post.edited ? " (edited)" : ""}
+                            · ${post.views || 0} views
                         </div>
                         <div class="post-content">
-                            ${post.content}
+                            ${post.contentHtml}
                         </div>
+                        ${this.renderAttachments(post.attachments)}
+                        ${this.renderPoll(post)}
+                        <div class="post-actions">
+                            ${this.renderReactions('post', post)}
+                            ${this.renderBookmarkButton('post', post)}
+                            <button id="follow-post-btn" class="${post.isFollowing ? 'active' : ''}"
+                                title="Get notified of new comments">${post.isFollowing ? 'Following' : 'Follow'}</button>
+                        </div>
+                        ${this.renderModerationTools(post)}
                     </div>
                     
                     <div class="comments-section">
                         <h3>Comments</h3>
                         <div class="comments-container">
-                            ${this.renderComments(post.comments)}
+                            ${post.commentCount === 0 ? '<p class="no-comments">No comments yet. Be the first to comment!</p>' : ''}
+                            ${this.renderComments(post.comments || [], post.commentsCursor, 0)}
                         </div>
                         
-                        <div class="comment-form-container">
-                            <h4>Add a Comment</h4>
-                            <form id="comment-form">
-                                <div class="form-group">
-                                    <textarea id="comment-content" name="content" rows="3" required></textarea>
-                                </div>
-                                <button type="submit">Post Comment</button>
-                            </form>
-                        </div>
+                        ${post.locked || post.archived ? `
+                            <p class="comments-closed">
+                                ${post.archived ? 'This post is archived and read-only.' : 'This post is locked and no longer accepts comments.'}
+                            </p>
+                        ` : `
+                            <div class="comment-form-container">
+                                <h4>Add a Comment</h4>
+                                <form id="comment-form">
+                                    <div class="form-group">
+                                        <textarea id="comment-content" name="content" rows="3" maxlength="20000" required></textarea>
+                                    </div>
+                                    <div class="form-group">
+                                        <input type="file" name="attachments" multiple accept="image/*,.pdf,.txt,.zip">
+                                    </div>
+                                    <button type="submit">Post Comment</button>
+                                </form>
+                            </div>
+                        `}
                     </div>
                 </div>
             `;
@@ -254,9 +1034,38 @@ const PostsComponent = {
                 });
                 
                 const commentForm = document.getElementById('comment-form');
-                commentForm.addEventListener('submit', (e) => {
-                    e.preventDefault();
-                    this.handleCreateComment(post.id);
+                if (commentForm) {
+                    commentForm.addEventListener('submit', (e) => {
+                        e.preventDefault();
+                        this.handleCreateComment(post.id, commentForm, 0);
+                    });
+                }
+                
+                const followBtn = document.getElementById('follow-post-btn');
+                followBtn.addEventListener('click', () => this.toggleFollow(post, followBtn));
+                
+                const moderationTools = document.querySelector('.moderation-tools');
+                if (moderationTools) {
+                    moderationTools.addEventListener('click', (e) => {
+                        const button = e.target.closest('button[data-action]');
+                        if (button) {
+                            this.handleModeration(post, button.dataset.action);
+                        }
+                    });
+                }
+                
+                const commentsContainer = document.querySelector('.comments-container');
+                commentsContainer.addEventListener('click', (e) => {
+                    const replyBtn = e.target.closest('.reply-btn');
+                    if (replyBtn) {
+                        this.showReplyForm(post.id, parseInt(replyBtn.dataset.commentId));
+                        return;
+                    }
+                    
+                    const loadMoreBtn = e.target.closest('.load-more-comments-btn');
+                    if (loadMoreBtn) {
+                        this.loadMoreComments(post.id, loadMoreBtn);
+                    }
                 });
             }, 0);
             
@@ -265,59 +1074,209 @@ const PostsComponent = {
         }
     },
     
-    // Render comments HTML
-    renderComments(comments) {
-        if (comments.length === 0) {
-            return '<p>No comments yet. Be the first to comment!</p>';
+    // Follow or unfollow the post being viewed
+    async toggleFollow(post, button) {
+        try {
+            const result = post.isFollowing ? await API.posts.unfollow(post.id) : await API.posts.follow(post.id);
+            post.isFollowing = result.following;
+            button.classList.toggle('active', result.following);
+            button.textContent = result.following ? 'Following' : 'Follow';
+        } catch (error) {
+            alert('Error updating follow: ' + error.message);
         }
-        
-        return comments.map(comment => `
+    },
+    
+    // Render the pinned, locked and archived states of a post
+    renderPostBadges(post) {
+        const badges = [];
+        if (post.pinned) {
+            badges.push('<span class="post-badge pinned">Pinned</span>');
+        }
+        if (post.locked) {
+            badges.push('<span class="post-badge locked">Locked</span>');
+        }
+        if (post.archived) {
+            badges.push('<span class="post-badge archived">Archived</span>');
+        }
+        return badges.join('');
+    },
+    
+    // Render the pin, lock and archive controls for moderators
+    renderModerationTools(post) {
+        const user = AuthService.user;
+        if (!user || (user.role !== 'moderator' && user.role !== 'admin') || post.status === 'draft') {
+            return '';
+        }
+        return `
+            <div class="moderation-tools">
+                ${post.archived ? '' : `
+                    <select id="pin-scope">
+                        <option value="0">Front page</option>
+                        ${(post.categories || []).map(category => `
+                            <option value="${category.id}">${category.name}</option>
+                        `).join('')}
+                    </select>
+                    <input type="number" id="pin-position" value="0" title="Position">
+                    <button data-action="pin">Pin</button>
+                    <button data-action="unpin">Unpin</button>
+                    <button data-action="lock">${post.locked ? 'Unlock' : 'Lock'}</button>
+                `}
+                <button data-action="archive">${post.archived ? 'Unarchive' : 'Archive'}</button>
+            </div>
+        `;
+    },
+    
+    // Pin, unpin, lock or archive the post being viewed, then show it again
+    async handleModeration(post, action) {
+        try {
+            switch (action) {
+            case 'pin':
+            case 'unpin':
+                await API.posts.pinPost(post.id, {
+                    pinned: action === 'pin',
+                    categoryId: parseInt(document.getElementById('pin-scope').value),
+                    position: parseInt(document.getElementById('pin-position').value) || 0
+                });
+                break;
+            case 'lock':
+                await API.posts.lockPost(post.id, !post.locked);
+                break;
+            case 'archive':
+                await API.posts.archivePost(post.id, !post.archived);
+                break;
+            }
+            this.handleViewComments(post.id);
+        } catch (error) {
+            alert('Error moderating post: ' + error.message);
+        }
+    },
+    
+    // Render a list of comments with their replies. parentId is 0 for the
+    // top-level comments; cursor, when set, loads more of the list.
+    renderComments(comments, cursor, parentId) {
+        return `
+            <div class="comment-replies" data-parent-id="${parentId}">
+                ${comments.map(comment => this.renderComment(comment)).join('')}
+                ${this.renderLoadMoreComments(parentId, cursor)}
+            </div>
+        `;
+    },
+    
+    // Render a single comment and its thread of replies
+    renderComment(comment) {
+        return `
             <div class="comment" data-comment-id="${comment.id}">
                 <div class="comment-meta">
-                    ${comment.user.nickname} commented on ${new Date(comment.createdAt).toLocaleString()}
+                    ${comment.user.nickname} ${comment.parentId ? 'replied' : 'commented'} on ${new Date(comment.createdAt).toLocaleString()}
                 </div>
                 <div class="comment-content">
-                    ${comment.content}
+                    ${comment.contentHtml}
                 </div>
+                ${this.renderAttachments(comment.attachments)}
+                <div class="comment-actions">
+                    ${this.renderReactions('comment', comment)}
+                    ${this.renderBookmarkButton('comment', comment)}
+                    ${this.currentPost && (this.currentPost.locked || this.currentPost.archived) ? '' : `
+                        <button class="reply-btn" data-comment-id="${comment.id}">Reply</button>
+                    `}
+                </div>
+                ${this.renderComments(comment.replies || [], comment.repliesCursor, comment.id)}
             </div>
-        `).join('');
+        `;
+    },
+    
+    renderLoadMoreComments(parentId, cursor) {
+        if (cursor === undefined || cursor === null) {
+            return '';
+        }
+        return `
+            <button class="load-more-comments-btn" data-parent-id="${parentId}" data-after="${cursor}">
+                ${parentId ? 'Load more replies' : 'Load more comments'}
+            </button>
+        `;
     },
     
-    // Handle creating a new comment
-    async handleCreateComment(postId) {
-        const content = document.getElementById('comment-content').value;
+    // Replace a "load more" button with the next page of its comment list
+    async loadMoreComments(postId, button) {
+        const parentId = parseInt(button.dataset.parentId);
         
         try {
-            const newComment = await API.posts.createComment(postId, content);
+            const page = await API.posts.getReplies(postId, parentId, button.dataset.after);
             
-            // Notify other users
+            // Skip comments already shown, such as ones just posted here
+            const html = page.items
+                .filter(comment => !document.querySelector(`.comment[data-comment-id="${comment.id}"]`))
+                .map(comment => this.renderComment(comment))
+                .join('');
+            button.insertAdjacentHTML('beforebegin', html + this.renderLoadMoreComments(parentId, page.nextCursor));
+            button.remove();
+        } catch (error) {
+            alert('Error loading comments: ' + error.message);
+        }
+    },
+    
+    // Show a form under a comment for replying to it
+    showReplyForm(postId, parentId) {
+        const comment = document.querySelector(`.comment[data-comment-id="${parentId}"]`);
+        const actions = comment.querySelector(':scope > .comment-actions');
+        if (comment.querySelector(':scope > .reply-form')) {
+            return;
+        }
+        
+        actions.insertAdjacentHTML('afterend', `
+            <form class="reply-form">
+                <div class="form-group">
+                    <textarea name="content" rows="2" maxlength="20000" required></textarea>
+                </div>
+                <button type="submit">Reply</button>
+                <button type="button" class="cancel-reply-btn">Cancel</button>
+            </form>
+        `);
+        
+        const form = comment.querySelector(':scope > .reply-form');
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            if (await this.handleCreateComment(postId, form, parentId)) {
+                form.remove();
+            }
+        });
+        form.querySelector('.cancel-reply-btn').addEventListener('click', () => form.remove());
+        form.querySelector('textarea').focus();
+    },
+    
+    // Handle creating a new comment, or a reply when parentId is set.
+    // Returns whether it was posted.
+    async handleCreateComment(postId, form, parentId) {
+        const content = form.querySelector('textarea').value;
+        
+        try {
+            const attachmentIds = await this.uploadAttachments(form.querySelector('input[type="file"]'));
+            const newComment = await API.posts.createComment(postId, content, parentId, attachmentIds);
+            
+            // Show it to the post's followers
             WebSocketService.sendNewCommentNotification(postId, newComment.id);
             
-            // Add comment to UI
-            const commentsContainer = document.querySelector('.comments-container');
-            const noCommentsMsg = commentsContainer.querySelector('p');
+            // Add comment to the end of its list, before any "load more" button
+            const noCommentsMsg = document.querySelector('.comments-container .no-comments');
             if (noCommentsMsg) {
-                commentsContainer.innerHTML = '';
+                noCommentsMsg.remove();
             }
             
-            const commentHTML = `
-                <div class="comment" data-comment-id="${newComment.id}">
-                    <div class="comment-meta">
-                        ${newComment.user.nickname} commented on ${new Date(newComment.createdAt).toLocaleString()}
-                    </div>
-                    <div class="comment-content">
-                        ${newComment.content}
-                    </div>
-                </div>
-            `;
-            
-            commentsContainer.insertAdjacentHTML('beforeend', commentHTML);
+            const list = document.querySelector(`.comment-replies[data-parent-id="${parentId}"]`);
+            const loadMoreBtn = list.querySelector(':scope > .load-more-comments-btn');
+            if (loadMoreBtn) {
+                loadMoreBtn.insertAdjacentHTML('beforebegin', this.renderComment(newComment));
+            } else {
+                list.insertAdjacentHTML('beforeend', this.renderComment(newComment));
+            }
             
             // Reset form
-            document.getElementById('comment-form').reset();
+            form.reset();
+            return true;
             
         } catch (error) {
             alert('Error creating comment: ' + error.message);
+            return false;
         }
     },
     
@@ -30,6 +30,10 @@ const API = {
                 responseData = {};
             }
             
+            if (response.status === 401 && response.headers.get('X-Session-Status') === 'expired') {
+                window.dispatchEvent(new CustomEvent('session-expired'));
+            }
+            
             if (!response.ok) {
                 throw new Error(responseText || 'API request failed');
             }
@@ -68,10 +72,35 @@ const API = {
         }
     },
     
+    // Session endpoints
+    sessions: {
+        getSessions() {
+            return API.request('/api/sessions');
+        },
+        
+        revokeSession(sessionId) {
+            return API.request(`/api/revoke-session?id=${sessionId}`, {
+                method: 'POST'
+            });
+        },
+        
+        logoutOthers() {
+            return API.request('/api/logout-others', {
+                method: 'POST'
+            });
+        }
+    },
+    
     // Posts endpoints
     posts: {
-        getAllPosts() {
-            return API.request('/api/posts');
+        getAllPosts(params = {}) {
+            const query = new URLSearchParams();
+            Object.entries(params).forEach(([key, value]) => {
+                if (value) {
+                    query.set(key, value);
+                }
+            });
+            return API.request(`/api/posts?${query}`);
         },
         
         getPost(postId) {
@@ -85,22 +114,220 @@ const API = {
             });
         },
         
-        createComment(postId, content) {
+        // Withdraw a post, or discard a draft
+        deletePost(postId) {
+            return API.request(`/api/delete-post?id=${postId}`, {
+                method: 'POST'
+            });
+        },
+        
+        getDrafts() {
+            return API.request('/api/drafts');
+        },
+        
+        // Create a draft, or update it when draft.id is set; a draft with
+        // publishAt set is published at that time, null unschedules it and
+        // leaving it out keeps its schedule
+        saveDraft(draft) {
+            return API.request('/api/save-draft', {
+                method: 'POST',
+                body: JSON.stringify(draft)
+            });
+        },
+        
+        publishDraft(postId) {
+            return API.request(`/api/publish-draft?id=${postId}`, {
+                method: 'POST'
+            });
+        },
+        
+        // Moderators only: pin or unpin a post on the front page (categoryId 0)
+        // or in one of its categories, lower positions first
+        pinPost(postId, { pinned, categoryId, position }) {
+            return API.request(`/api/pin-post?id=${postId}`, {
+                method: 'POST',
+                body: JSON.stringify({ pinned, categoryId, position })
+            });
+        },
+        
+        // Moderators only: close a post to new comments, or reopen it
+        lockPost(postId, locked) {
+            return API.request(`/api/lock-post?id=${postId}`, {
+                method: 'POST',
+                body: JSON.stringify({ locked })
+            });
+        },
+        
+        // Moderators only: make a post read-only and hide it from the feed, or restore it
+        archivePost(postId, archived) {
+            return API.request(`/api/archive-post?id=${postId}`, {
+                method: 'POST',
+                body: JSON.stringify({ archived })
+            });
+        },
+        
+        // Like or dislike a post or comment; reacting the same way again takes it back
+        react(targetType, targetId, reaction) {
+            return API.request(`/api/react-${targetType}?id=${targetId}`, {
+                method: 'POST',
+                body: JSON.stringify({ reaction })
+            });
+        },
+        
+        // Cast a ballot in a poll, replacing any earlier one
+        vote(pollId, optionIds) {
+            return API.request(`/api/vote-poll?id=${pollId}`, {
+                method: 'POST',
+                body: JSON.stringify({ optionIds })
+            });
+        },
+        
+        unvote(pollId) {
+            return API.request(`/api/unvote-poll?id=${pollId}`, {
+                method: 'POST'
+            });
+        },
+        
+        // Save a post or comment to read later, optionally in a folder;
+        // bookmarking it again moves it
+        bookmark(targetType, targetId, folder = '') {
+            return API.request(`/api/bookmark-${targetType}?id=${targetId}`, {
+                method: 'POST',
+                body: JSON.stringify({ folder })
+            });
+        },
+        
+        unbookmark(targetType, targetId) {
+            return API.request(`/api/unbookmark-${targetType}?id=${targetId}`, {
+                method: 'POST'
+            });
+        },
+        
+        // A page of the user's bookmarks, newest first, optionally in one folder
+        getBookmarks(params = {}) {
+            const query = new URLSearchParams();
+            Object.entries(params).forEach(([key, value]) => {
+                if (value) {
+                    query.set(key, value);
+                }
+            });
+            return API.request(`/api/bookmarks?${query}`);
+        },
+        
+        getBookmarkFolders() {
+            return API.request('/api/bookmark-folders');
+        },
+        
+        // Follow a post to be notified of new comments on it
+        follow(postId) {
+            return API.request(`/api/follow-post?id=${postId}`, {
+                method: 'POST'
+            });
+        },
+        
+        unfollow(postId) {
+            return API.request(`/api/unfollow-post?id=${postId}`, {
+                method: 'POST'
+            });
+        },
+        
+        // Reply to another comment by passing its ID as parentId
+        createComment(postId, content, parentId = 0, attachmentIds = []) {
             return API.request(`/api/comments?postId=${postId}`, {
                 method: 'POST',
-                body: JSON.stringify({ content })
+                body: JSON.stringify({ content, parentId, attachmentIds })
+            });
+        },
+        
+        // Upload a file to attach to a post or comment; the response's id goes
+        // in attachmentIds
+        async uploadFile(file) {
+            const formData = new FormData();
+            formData.append('file', file);
+            
+            const response = await fetch('/api/upload-file', {
+                method: 'POST',
+                credentials: 'include',
+                body: formData
+            });
+            
+            if (!response.ok) {
+                throw new Error(await response.text() || 'File upload failed');
+            }
+            return response.json();
+        },
+        
+        // Next page of replies to a comment, or of top-level comments when parentId is 0
+        getReplies(postId, parentId, after) {
+            const query = new URLSearchParams({ postId, after });
+            if (parentId) {
+                query.set('parentId', parentId);
+            }
+            return API.request(`/api/comment-replies?${query}`);
+        }
+    },
+    
+    // Tags endpoints
+    tags: {
+        // Tags with their usage counts, most used first; with a prefix, the
+        // tags starting with it
+        getTags(prefix = '') {
+            const query = new URLSearchParams();
+            if (prefix) {
+                query.set('q', prefix);
+            }
+            return API.request(`/api/tags?${query}`);
+        }
+    },
+    
+    // Notifications endpoints
+    notifications: {
+        // A page of the user's notifications, newest first, with how many
+        // are unread
+        getNotifications(cursor = null) {
+            const query = new URLSearchParams();
+            if (cursor) {
+                query.set('cursor', cursor);
+            }
+            return API.request(`/api/notifications?${query}`);
+        },
+        
+        // Mark notifications up to upToId as read, or all of them without it
+        markRead(upToId = 0) {
+            return API.request('/api/read-notifications', {
+                method: 'POST',
+                body: JSON.stringify({ upToId })
             });
         }
     },
     
+    // Search endpoint
+    search(params = {}) {
+        const query = new URLSearchParams();
+        Object.entries(params).forEach(([key, value]) => {
+            if (value) {
+                query.set(key, value);
+            }
+        });
+        return API.request(`/api/search?${query}`);
+    },
+    
+    // Categories endpoints
+    categories: {
+        getCategories() {
+            return API.request('/api/categories');
+        }
+    },
+    
     // Messages endpoints
     messages: {
         getChats() {
             return API.request('/api/chats');
         },
         
-        getMessages(userId, limit = 10, offset = 0) {
-            return API.request(`/api/messages?userId=${userId}&limit=${limit}&offset=${offset}`);
+        getMessages(userId, limit = 10, before = null) {
+            const cursor = before ? `&before=${before}` : '';
+            return API.request(`/api/messages?userId=${userId}&limit=${limit}${cursor}`);
         },
         
         // frontend/js/services/api.js - Update messages.sendMessage
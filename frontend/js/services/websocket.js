@@ -6,6 +6,10 @@ const WebSocketService = {
     onlineStatusHandlers: [],
     postHandlers: [],
     commentHandlers: [],
+    readReceiptHandlers: [],
+    reactionHandlers: [],
+    pollHandlers: [],
+    notificationHandlers: [],
     reconnectInterval: null,
     messageQueue: [],
     processingQueue: false,
@@ -38,7 +42,7 @@ const WebSocketService = {
         
         // Create new connection
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        this.socket = new WebSocket(`${protocol}//${window.location.host}/ws?userId=${this.userId}`);
+        this.socket = new WebSocket(`${protocol}//${window.location.host}/ws`);
         
         // Setup event handlers
         this.socket.onopen = () => {
@@ -157,6 +161,26 @@ const WebSocketService = {
             case 'new_comment':
                 this.commentHandlers.forEach(handler => handler(message.payload));
                 break;
+                
+            case 'read_receipt':
+                this.readReceiptHandlers.forEach(handler => handler(message.payload));
+                break;
+                
+            case 'reaction_update':
+                this.reactionHandlers.forEach(handler => handler(message.payload));
+                break;
+                
+            case 'poll_update':
+                this.pollHandlers.forEach(handler => handler(message.payload));
+                break;
+                
+            case 'notification':
+                this.notificationHandlers.forEach(handler => handler(message.payload));
+                break;
+                
+            case 'error':
+                console.warn(`WebSocket ${message.payload.requestType || ''} frame rejected:`, message.payload.message);
+                break;
         }
     },
     
@@ -185,27 +209,51 @@ const WebSocketService = {
         this.commentHandlers.push(handler);
     },
     
+    // Register read receipt handler
+    onReadReceipt(handler) {
+        this.readReceiptHandlers.push(handler);
+    },
+    
+    // Register reaction count handler
+    onReactionUpdate(handler) {
+        this.reactionHandlers.push(handler);
+    },
+    
+    // Register poll tally handler
+    onPollUpdate(handler) {
+        this.pollHandlers.push(handler);
+    },
+    
+    // Register notification handler
+    onNotification(handler) {
+        this.notificationHandlers.push(handler);
+    },
+    
     // Send a chat message
     sendChatMessage(receiverId, content, imageUrl = '') {
         return this.send('chat_message', {
             receiverId,
             content,
-            imageUrl,
-            senderId: AuthService.user.id,
-            senderName: AuthService.user.nickname,
-            createdAt: new Date()
+            imageUrl
         });
     },
     
     // Send typing status
     sendTypingStatus(receiverId, isTyping) {
         return this.send('typing', {
-            senderId: AuthService.user.id,
             receiverId,
             isTyping
         });
     },
     
+    // Mark the conversation with a user as read up to a message
+    sendMarkRead(userId, upToId) {
+        return this.send('mark_read', {
+            userId,
+            upToId
+        });
+    },
+    
     // Send new post notification
     sendNewPostNotification(postId) {
         return this.send('new_post', {
@@ -213,7 +261,7 @@ const WebSocketService = {
         });
     },
     
-    // Send new comment notification
+    // Announce a new comment to the post's followers
     sendNewCommentNotification(postId, commentId) {
         return this.send('new_comment', {
             postId,
@@ -4,10 +4,12 @@ package main
 import (
     "log"
     "net/http"
+    "time"
     
     "forum/backend/controllers"
     "forum/backend/database"
     "forum/backend/middleware"
+    "forum/backend/models"
     "forum/backend/routes"
     "forum/backend/websocket"
 )
@@ -18,9 +20,9 @@ func main() {
     defer db.Close()
     
     // Initialize controllers
-    authController := &controllers.AuthController{DB: db}
-    postController := &controllers.PostController{DB: db}
-    messageController := &controllers.MessageController{DB: db}
+    categoryController := &controllers.CategoryController{DB: db}
+    searchController := &controllers.SearchController{DB: db}
+    tagController := &controllers.TagController{DB: db}
     profileController := &controllers.ProfileController{DB: db}
 
 	// Initialize upload controller
@@ -30,6 +32,23 @@ func main() {
     // Initialize WebSocket hub
     hub := websocket.NewHub(db)
     go hub.Run()
+
+    // Purge expired sessions in the background
+    go models.StartSessionReaper(db, time.Hour, hub.DisconnectSession)
+    
+    // Publish scheduled drafts when their time comes, announcing them like
+    // any new post
+    go models.StartPostScheduler(db, 30*time.Second, func(postID int) {
+        hub.BroadcastEvent("new_post", websocket.PostMessage{PostID: postID})
+    })
+    
+    // Keep the hot and top rankings of the feed up to date
+    go models.StartRankingRefresher(db, 5*time.Minute)
+
+    postController := &controllers.PostController{DB: db, Hub: hub}
+    authController := &controllers.AuthController{DB: db, Hub: hub}
+    messageController := &controllers.MessageController{DB: db, Hub: hub}
+    sessionController := &controllers.SessionController{DB: db, Hub: hub}
     
     // Static files
     http.Handle("/", http.FileServer(http.Dir("./frontend")))
@@ -43,10 +62,41 @@ func main() {
     http.HandleFunc("/api/logout", authController.Logout)
     http.HandleFunc("/api/me", authController.GetCurrentUser)
     
+    // Session routes (with authentication)
+    http.HandleFunc("/api/sessions", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        sessionController.GetSessions(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/revoke-session", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        sessionController.RevokeSession(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/logout-others", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        sessionController.RevokeOtherSessions(w, r, userID)
+    }))
+    
     // Post routes (with authentication)
     http.HandleFunc("/api/posts", func(w http.ResponseWriter, r *http.Request) {
         if r.Method == http.MethodGet {
-            postController.GetAllPosts(w, r)
+            middleware.OptionalAuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+                viewerID, _ := middleware.GetUserID(r)
+                postController.GetAllPosts(w, r, viewerID)
+            })(w, r)
         } else {
             middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
                 userID, ok := middleware.GetUserID(r)
@@ -59,7 +109,277 @@ func main() {
         }
     })
     
-    http.HandleFunc("/api/post", postController.GetPost)
+    http.HandleFunc("/api/post", middleware.OptionalAuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        viewerID, _ := middleware.GetUserID(r)
+        postController.GetPost(w, r, viewerID)
+    }))
+    
+    http.HandleFunc("/api/edit-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.EditPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/delete-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.DeletePost(w, r, userID)
+    }))
+    
+    // Draft routes (with authentication)
+    http.HandleFunc("/api/drafts", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.GetDrafts(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/save-draft", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.SaveDraft(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/publish-draft", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.PublishDraft(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/comment-replies", middleware.OptionalAuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        viewerID, _ := middleware.GetUserID(r)
+        postController.GetCommentReplies(w, r, viewerID)
+    }))
+    
+    http.HandleFunc("/api/post-revisions", postController.GetPostRevisions)
+    http.HandleFunc("/api/post-diff", postController.GetPostDiff)
+    
+    http.HandleFunc("/api/react-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.ReactToPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/react-comment", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.ReactToComment(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/vote-poll", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.Vote(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/unvote-poll", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.Unvote(w, r, userID)
+    }))
+    
+    // Bookmark routes
+    http.HandleFunc("/api/bookmark-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.BookmarkPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/unbookmark-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.UnbookmarkPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/bookmark-comment", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.BookmarkComment(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/unbookmark-comment", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.UnbookmarkComment(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/bookmarks", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.GetBookmarks(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/bookmark-folders", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.GetBookmarkFolders(w, r, userID)
+    }))
+    
+    // Follow and notification routes
+    http.HandleFunc("/api/follow-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.FollowPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/unfollow-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.UnfollowPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/notifications", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.GetNotifications(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/read-notifications", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.ReadNotifications(w, r, userID)
+    }))
+    
+    // Post moderation routes (moderators only)
+    http.HandleFunc("/api/pin-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.PinPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/lock-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.LockPost(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/archive-post", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        postController.ArchivePost(w, r, userID)
+    }))
+    
+    // Search route; messages are only searched for logged in users
+    http.HandleFunc("/api/search", middleware.OptionalAuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        viewerID, _ := middleware.GetUserID(r)
+        searchController.Search(w, r, viewerID)
+    }))
+    
+    // Category routes
+    http.HandleFunc("/api/categories", categoryController.GetCategories)
+    
+    http.HandleFunc("/api/create-category", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        categoryController.CreateCategory(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/update-category", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        categoryController.UpdateCategory(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/delete-category", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        categoryController.DeleteCategory(w, r, userID)
+    }))
+    
+    // Tag routes
+    http.HandleFunc("/api/tags", tagController.GetTags)
+    
+    http.HandleFunc("/api/rename-tag", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        tagController.RenameTag(w, r, userID)
+    }))
+    
+    http.HandleFunc("/api/merge-tags", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        tagController.MergeTags(w, r, userID)
+    }))
     
     http.HandleFunc("/api/comments", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
         userID, ok := middleware.GetUserID(r)
@@ -89,6 +409,15 @@ func main() {
         messageController.SendMessage(w, r, userID)
     }))
     
+    http.HandleFunc("/api/mark-read", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+        userID, ok := middleware.GetUserID(r)
+        if !ok {
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        messageController.MarkRead(w, r, userID)
+    }))
+    
     http.HandleFunc("/api/chats", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
         userID, ok := middleware.GetUserID(r)
         if !ok {
@@ -122,6 +451,15 @@ func main() {
 		uploadController.UploadImage(w, r, userID)
 	}))
 
+	http.HandleFunc("/api/upload-file", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := middleware.GetUserID(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		uploadController.UploadFile(w, r, userID)
+	}))
+
 	http.HandleFunc("/api/upload-avatar", middleware.AuthMiddleware(db, func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := middleware.GetUserID(r)
 		if !ok {